@@ -0,0 +1,133 @@
+package renamer
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
+)
+
+const applyCmd = "apply"
+
+type applyFlags struct {
+	stateDir    string
+	dryRun, yes bool
+}
+
+func parseApplyFlags() *applyFlags {
+	flags := &applyFlags{}
+
+	flagItems := []utils.FlagItem{
+		{
+			Name:   "state directory",
+			Desc:   "Directory where the undo journals are stored, empty to use $XDG_STATE_HOME/dyno-clis/renamer",
+			Flags:  []string{"state-dir"},
+			StrVal: &flags.stateDir,
+		},
+		{
+			Name:    "dry run",
+			Desc:    "Check the plan and display the files that will be renamed without renaming them",
+			Flags:   []string{"dry-run"},
+			BoolVal: &flags.dryRun,
+		},
+		{
+			Name:    "yes",
+			Desc:    "Skip confirmation prompt",
+			Flags:   []string{"y", "yes"},
+			BoolVal: &flags.yes,
+		},
+	}
+
+	utils.ParseFlags(flagItems, cliName+" "+applyCmd+" plan.json")
+
+	return flags
+}
+
+// runApply runs a plan written by --plan-out, after checking that its source files are
+// unchanged and that no target has been taken in the meantime
+func runApply(planPath string, flags *applyFlags) error {
+	plan, err := rename.ReadPlan(planPath)
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := plan.FindConflicts()
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Renames) == 0 {
+		fmt.Println("No files to rename!")
+		return nil
+	}
+
+	if problems := plan.CheckSources(); len(problems) > 0 {
+		fmt.Printf("--- Changed files (%d) ---\n", len(problems))
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("%d source files changed since the plan was written, write a new plan", len(problems))
+	}
+
+	if len(conflicts) > 0 {
+		for i := range conflicts {
+			conflicts[i].Resolution = "aborted"
+		}
+		displayConflicts(conflicts)
+		return fmt.Errorf("%d %w", len(conflicts), rename.ErrConflict)
+	}
+
+	fmt.Printf("\n--- Apply ---\n")
+	fmt.Printf("Plan: %s\n", planPath)
+	if plan.Path != "" {
+		fmt.Printf("Path: %s\n", plan.Path)
+	}
+	fmt.Printf("Number of files to rename: %d\n", len(plan.Renames))
+
+	if flags.dryRun {
+		fmt.Println("--- Dry run mode, will not rename the files ---")
+		fmt.Println("------------------------------------------------")
+		for _, r := range plan.Renames {
+			fmt.Printf("%s ➡️  %s\n", r.OldPath, r.NewPath)
+		}
+		return nil
+	}
+
+	if !flags.yes && !utils.ConfirmAction("Do you want to continue? (Y/n): ", true) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	// The undo journal records the command that built the plan and this one, the subcommand
+	// has been dropped from os.Args
+	if plan.Path == "" {
+		plan.Path = filepath.Dir(planPath)
+	}
+	opts := rename.ApplyOptions{StateDir: flags.stateDir, Args: append([]string{applyCmd}, os.Args[1:]...)}
+	// CSV plans don't keep the command that built them
+	if len(plan.Args) == 0 {
+		plan.Args, opts.Args = opts.Args, nil
+	}
+
+	executeRenames(plan, opts)
+	return nil
+}
+
+func executeApply() {
+	// Drop the subcommand so the remaining arguments are parsed as its flags
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	flags := parseApplyFlags()
+
+	if flag.NArg() == 0 {
+		fmt.Println("Please provide the plan file:", cliName, applyCmd, "plan.json")
+		os.Exit(1)
+	}
+
+	if err := runApply(flag.Arg(0), flags); err != nil {
+		fmt.Println("Failed to apply plan", err)
+		os.Exit(1)
+	}
+}
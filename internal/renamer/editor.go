@@ -0,0 +1,162 @@
+package renamer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
+)
+
+const editorHeader = `# Edit the names below, then save and close the editor.
+# Keep the number at the start of each line, remove a line to leave its file untouched.
+# Lines starting with # are ignored.
+`
+
+// "0001 name.jpg", the number links the line to the original file
+var editorLineRegex = regexp.MustCompile(`^(\d+) (.*)$`)
+
+func getEditorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	return []string{"vi"}
+}
+
+// writeEditorFile writes one numbered line per file, paths are relative to root
+func writeEditorFile(w io.Writer, names []string) error {
+	if _, err := io.WriteString(w, editorHeader); err != nil {
+		return err
+	}
+
+	width := len(strconv.Itoa(len(names)))
+	for i, name := range names {
+		if _, err := fmt.Fprintf(w, "%0*d %s\n", width, i+1, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEditorFile returns the edited names by line number (from 1). A file can only be
+// renamed inside its own directory
+func parseEditorFile(r io.Reader, names []string) (map[int]string, error) {
+	edited := map[int]string{}
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		matches := editorLineRegex.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("line %d: invalid format, expected: <number> <name>", lineNumber)
+		}
+
+		index, _ := strconv.Atoi(matches[1])
+		if index < 1 || index > len(names) {
+			return nil, fmt.Errorf("line %d: unknown file number %s", lineNumber, matches[1])
+		}
+		if _, exists := edited[index]; exists {
+			return nil, fmt.Errorf("line %d: file number %s is used more than once", lineNumber, matches[1])
+		}
+
+		// Spaces are part of the name, only the line terminator has been dropped
+		newName := filepath.Clean(matches[2])
+		if strings.TrimSpace(newName) == "" || newName == "." || strings.HasSuffix(matches[2], string(filepath.Separator)) {
+			return nil, fmt.Errorf("line %d: empty name", lineNumber)
+		}
+		if filepath.Dir(newName) != filepath.Dir(names[index-1]) {
+			return nil, fmt.Errorf("line %d: %s can only be renamed inside its directory", lineNumber, names[index-1])
+		}
+
+		edited[index] = newName
+	}
+
+	return edited, scanner.Err()
+}
+
+// openEditor lets the user edit the names in $VISUAL or $EDITOR and returns the edited file
+func openEditor(names []string) (map[int]string, error) {
+	f, err := os.CreateTemp("", cliName+"-*.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	if err := writeEditorFile(f, names); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.Close()
+
+	editor := getEditorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run editor %s: %w", editor[0], err)
+	}
+
+	edited, err := os.Open(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer edited.Close()
+
+	return parseEditorFile(edited, names)
+}
+
+// processEdit plans the renames of the names edited by the user
+func processEdit(path string, flags *cliFlags) (*rename.Plan, error) {
+	paths, report, err := rename.Files(path, flags.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, filePath := range paths {
+		rel, err := filepath.Rel(path, filePath)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+
+	renamed := map[string]string{}
+	if len(names) > 0 {
+		edited, err := openEditor(names)
+		if err != nil {
+			return nil, err
+		}
+
+		for index, newName := range edited {
+			oldPath, newPath := filepath.Join(path, names[index-1]), filepath.Join(path, newName)
+			if oldPath == newPath {
+				continue
+			}
+
+			if other, exists := renamed[newPath]; exists {
+				return nil, fmt.Errorf("%s and %s are both renamed to %s", other, oldPath, newName)
+			}
+			renamed[newPath] = oldPath
+		}
+	}
+
+	plan, err := rename.NewPlan(path, renamed, flags.Options)
+	if plan != nil {
+		plan.Report = report
+	}
+	return plan, err
+}
@@ -0,0 +1,63 @@
+package renamer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEditorFile(t *testing.T) {
+	names := []string{"a.jpg", "b.jpg", "sub/c.jpg", " d.jpg "}
+
+	testCases := []struct {
+		content  string
+		expected map[int]string
+		isError  bool
+	}{
+		{content: editorHeader + "1 b.jpg\n2 a.jpg\n3 sub/C.jpg\n", expected: map[int]string{1: "b.jpg", 2: "a.jpg", 3: "sub/C.jpg"}},
+		{content: "# comment\n\n2 orange cat.jpg \n", expected: map[int]string{2: "orange cat.jpg "}},
+		{content: "1 a.jpg\r\n", expected: map[int]string{1: "a.jpg"}},
+		{content: "4  d.jpg \r\n", expected: map[int]string{4: " d.jpg "}},
+		{content: "a.jpg\n", isError: true},
+		{content: "5 e.jpg\n", isError: true},
+		{content: "1 a.jpg\n1 b.jpg\n", isError: true},
+		{content: "1 \n", isError: true},
+		{content: "1   \n", isError: true},
+		{content: "3 c.jpg\n", isError: true},
+		{content: "1 sub/a.jpg\n", isError: true},
+	}
+
+	for _, tc := range testCases {
+		edited, err := parseEditorFile(strings.NewReader(tc.content), names)
+		if (err != nil) != tc.isError {
+			t.Errorf("FAIL => Input: %q, Expected error: %v - Actual: '%v'", tc.content, tc.isError, err)
+			continue
+		}
+
+		for index, name := range tc.expected {
+			if edited[index] != name {
+				t.Errorf("FAIL => Input: %q, Expected: '%v' - Actual: '%v'", tc.content, tc.expected, edited)
+			}
+		}
+		if !tc.isError && len(edited) != len(tc.expected) {
+			t.Errorf("FAIL => Input: %q, Expected: '%v' - Actual: '%v'", tc.content, tc.expected, edited)
+		}
+	}
+}
+
+func TestWriteEditorFile(t *testing.T) {
+	names := make([]string, 12)
+	for i := range names {
+		names[i] = "file.jpg"
+	}
+
+	content := strings.Builder{}
+	writeEditorFile(&content, names)
+
+	edited, err := parseEditorFile(strings.NewReader(content.String()), names)
+	if err != nil || len(edited) != len(names) {
+		t.Errorf("FAIL => Expected: %d unchanged names - Actual: '%v', '%v'", len(names), edited, err)
+	}
+	if !strings.Contains(content.String(), "\n01 file.jpg\n") {
+		t.Errorf("FAIL => Expected: zero-padded line numbers - Actual: %q", content.String())
+	}
+}
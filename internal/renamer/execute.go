@@ -1,131 +1,353 @@
 package renamer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"strings"
-	"sync"
-	"syscall"
 	"time"
 
 	"github.com/dynonguyen/dyno-clis/internal/utils"
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
 )
 
-const (
-	cliName           = "renamer"
-	suffixFlag        = "suffix"
-	emptyOverrideFlag = "<empty>"
-)
+const cliName = "renamer"
 
 type cliFlags struct {
-	yes, allowDir, dryRun bool
-	path, prefix, suffix, override, separator,
-	include, exclude, detectResolution, createdDate, replace string
-	uniqueSuffix bool
-}
-
-type replacer struct {
-	regex       *regexp.Regexp
-	replacement string
+	rename.Options
+	yes, dryRun, edit, watch        bool
+	path, stateDir, planOut, settle string
 }
 
-var defaultFlags = cliFlags{
-	path:             "",
-	prefix:           "",
-	suffix:           "",
-	override:         "",
-	separator:        "_",
-	include:          "",
-	exclude:          "",
-	createdDate:      "",
-	detectResolution: "",
-	replace:          "",
-	yes:              false,
-	allowDir:         false,
-	dryRun:           false,
-	uniqueSuffix:     false,
-}
-
-func parseFlags() *cliFlags {
-	flags := defaultFlags
+var defaultFlags = cliFlags{Options: rename.DefaultOptions(), settle: rename.DefaultSettle.String()}
 
-	flagItems := []utils.FlagItem{
+// selectionFlagItems are the flags that choose the files, shared by the commands
+func selectionFlagItems(flags *cliFlags) []utils.FlagItem {
+	return []utils.FlagItem{
 		{
 			Name:   "path",
-			Desc:   "Path to the directory to rename files in, empty to use current directory",
+			Desc:   "Path to the directory of the files, empty to use current directory",
 			Flags:  []string{"p", "path"},
 			StrVal: &flags.path,
 		},
+		{
+			Name:    "recursive",
+			Desc:    "Include files in all subdirectories",
+			Flags:   []string{"r", "recursive"},
+			BoolVal: &flags.Recursive,
+		},
+		{
+			Name:    "max depth",
+			Desc:    "Limit how deep the recursive mode goes, 0 for no limit",
+			Flags:   []string{"max-depth"},
+			Example: "2",
+			IntVal:  &flags.MaxDepth,
+		},
+		{
+			Name:    "allow directories",
+			Desc:    "Include directories, not just files",
+			Flags:   []string{"allow-dir"},
+			BoolVal: &flags.AllowDir,
+		},
+		{
+			Name:    "hidden",
+			Desc:    "Also include hidden files (starting with a dot), and walk hidden directories in recursive mode",
+			Flags:   []string{"hidden"},
+			BoolVal: &flags.Hidden,
+		},
+		{
+			Name:       "symlinks",
+			Desc:       "What to do with symbolic links: skip, link (the link itself) or target (the file it points to, inside the path only)",
+			Flags:      []string{"symlinks"},
+			DefaultVal: defaultFlags.Symlinks,
+			StrVal:     &flags.Symlinks,
+		},
+		{
+			Name:        "filename date",
+			Desc:        "Regex with named groups (year, month, day, hour, minute, second, ampm) of the dates in the file names, tried before the built-in patterns",
+			Flags:       []string{"filename-date"},
+			Example:     `DSC(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`,
+			SliceStrVal: &flags.FilenameDates,
+		},
+		{
+			Name:   "include",
+			Desc:   "Only include files that match the given regex",
+			Flags:  []string{"include"},
+			StrVal: &flags.Include,
+		},
+		{
+			Name:   "exclude",
+			Desc:   "Exclude files that match the given regex",
+			Flags:  []string{"exclude"},
+			StrVal: &flags.Exclude,
+		},
+		{
+			Name:        "glob",
+			Desc:        "Only include files whose name matches one of the shell globs",
+			Flags:       []string{"glob"},
+			Example:     "*.jpg",
+			SliceStrVal: &flags.Globs,
+		},
+		{
+			Name:        "exclude glob",
+			Desc:        "Exclude files whose name matches one of the shell globs",
+			Flags:       []string{"exclude-glob"},
+			Example:     "*_edited.*",
+			SliceStrVal: &flags.ExcludeGlobs,
+		},
+		{
+			Name:    "kind",
+			Desc:    "Only include media of the given kinds, comma separated",
+			Flags:   []string{"kind"},
+			Example: "photo,video",
+			StrVal:  &flags.Kind,
+		},
+		{
+			Name:    "min size",
+			Desc:    "Only include files of at least this size, units in powers of 1024",
+			Flags:   []string{"min-size"},
+			Example: "500K",
+			StrVal:  &flags.MinSize,
+		},
+		{
+			Name:    "max size",
+			Desc:    "Only include files of at most this size, units in powers of 1024",
+			Flags:   []string{"max-size"},
+			Example: "2G",
+			StrVal:  &flags.MaxSize,
+		},
+		{
+			Name:    "after",
+			Desc:    "Only include files dated on or after this date, from --date-source",
+			Flags:   []string{"after"},
+			Example: "2024-01-01",
+			StrVal:  &flags.After,
+		},
+		{
+			Name:    "before",
+			Desc:    "Only include files dated before this date, from --date-source",
+			Flags:   []string{"before"},
+			Example: "2024-07-01T12:00:00",
+			StrVal:  &flags.Before,
+		},
+	}
+}
+
+func parseFlags() *cliFlags {
+	flags := defaultFlags
+
+	flagItems := append(selectionFlagItems(&flags), []utils.FlagItem{
 		{
 			Name:   "prefix",
 			Desc:   "Prefix to add to the file name",
 			Flags:  []string{"prefix"},
-			StrVal: &flags.prefix,
+			StrVal: &flags.Prefix,
 		},
 		{
 			Name:   "suffix",
 			Desc:   "Suffix to add to the file name",
 			Flags:  []string{"suffix"},
-			StrVal: &flags.suffix,
+			StrVal: &flags.Suffix,
 		},
 		{
 			Name:   "override",
 			Desc:   "Override the file name with the given name, empty to keep the original name",
 			Flags:  []string{"override"},
-			StrVal: &flags.override,
+			StrVal: &flags.Override,
 		},
 		{
 			Name:       "separator",
 			Desc:       "Separator to use between the prefix, suffix and the original file name",
 			Flags:      []string{"separator"},
-			DefaultVal: defaultFlags.separator,
-			StrVal:     &flags.separator,
+			DefaultVal: defaultFlags.Separator,
+			StrVal:     &flags.Separator,
 		},
 		{
-			Name:    "allow directories",
-			Desc:    "Allow renaming directories",
-			Flags:   []string{"allow-dir"},
-			BoolVal: &flags.allowDir,
+			Name:    "template",
+			Desc:    "Template of the new name, replaces --prefix, --suffix, --override, --created-date, --detect-resolution and --unique-suffix",
+			Example: "{date:YYYY-MM-DD}_{name|lower}_{res}",
+			Flags:   []string{"t", "template"},
+			StrVal:  &flags.Template,
+		},
+		{
+			Name:   "case",
+			Desc:   "Convert the case of the new name: lower, upper, title, camel, snake or kebab",
+			Flags:  []string{"case"},
+			StrVal: &flags.CaseStyle,
+		},
+		{
+			Name:    "ascii",
+			Desc:    "Transliterate Unicode letters to ASCII, e.g. Đà Lạt => Da Lat",
+			Flags:   []string{"ascii"},
+			BoolVal: &flags.ASCII,
+		},
+		{
+			Name:    "collapse spaces",
+			Desc:    "Collapse consecutive whitespace into a single space and trim the new name",
+			Flags:   []string{"collapse-spaces"},
+			BoolVal: &flags.CollapseSpaces,
+		},
+		{
+			Name:    "safe characters",
+			Desc:    "Remove characters that are not allowed on Windows or FAT (<>:\"/\\|?*)",
+			Flags:   []string{"safe-chars"},
+			BoolVal: &flags.SafeChars,
+		},
+		{
+			Name:    "normalize extension",
+			Desc:    "Lowercase the extension and use the common spelling (.JPEG => .jpg)",
+			Flags:   []string{"normalize-ext"},
+			BoolVal: &flags.NormalizeExt,
 		},
 		{
 			Name:    "created date",
 			Desc:    "Add created date to the file name with the given format",
 			Example: "YYYY-MM-DD",
 			Flags:   []string{"created-date"},
-			StrVal:  &flags.createdDate,
+			StrVal:  &flags.CreatedDate,
+		},
+		{
+			Name:       "date source",
+			Desc:       "Where the created date comes from, tried in order: exif, quicktime, mtime, btime, filename",
+			Example:    "exif,quicktime,btime,mtime",
+			Flags:      []string{"date-source"},
+			DefaultVal: defaultFlags.DateSource,
+			StrVal:     &flags.DateSource,
+		},
+		{
+			Name:    "set mtime",
+			Desc:    "Set the modified time of the renamed files to the date in their original name",
+			Flags:   []string{"set-mtime"},
+			BoolVal: &flags.SetModTime,
 		},
 		{
 			Name:    "detect resolution",
 			Desc:    "Auto detect resolution and add to the file name, only for photo & video files",
 			Flags:   []string{"detect-resolution"},
 			Example: "prefix or suffix",
-			StrVal:  &flags.detectResolution,
+			StrVal:  &flags.DetectResolution,
 		},
 		{
-			Name:   "include",
-			Desc:   "Only rename files that match the given regex",
-			Flags:  []string{"include"},
-			StrVal: &flags.include,
+			Name:    "aspect ratio",
+			Desc:    "Add the aspect ratio after the resolution of --detect-resolution, the value separates the two numbers. Snapped to common ratios like 16:9, 4:3, 21:9 or 9:16 when close enough",
+			Flags:   []string{"aspect-ratio"},
+			Example: "x for 16x9, - for 16-9",
+			StrVal:  &flags.AspectRatio,
 		},
 		{
-			Name:   "exclude",
-			Desc:   "Exclude files that match the given regex",
-			Flags:  []string{"exclude"},
-			StrVal: &flags.exclude,
+			Name:        "replace",
+			Desc:        "Replace the given string or regex with the given replacement, format: old=new (\\= for a literal =) or s/old/new/flags (flags: i ignore case, l literal)",
+			Flags:       []string{"replace"},
+			SliceStrVal: &flags.Replace,
+		},
+		{
+			Name:   "replace file",
+			Desc:   "File with one replace rule per line, applied before the --replace flags",
+			Flags:  []string{"replace-file"},
+			StrVal: &flags.ReplaceFile,
 		},
 		{
-			Name:   "replace",
-			Desc:   "Replace the given string or regex with the given replacement, format: old=new",
-			Flags:  []string{"replace"},
-			StrVal: &flags.replace,
+			Name:    "replace literal",
+			Desc:    "Treat every replace rule as a plain string instead of a regex",
+			Flags:   []string{"replace-literal"},
+			BoolVal: &flags.ReplaceLiteral,
+		},
+		{
+			Name:    "replace ignore case",
+			Desc:    "Match every replace rule case-insensitively",
+			Flags:   []string{"replace-ignore-case"},
+			BoolVal: &flags.ReplaceIgnoreCase,
 		},
 		{
 			Name:    "unique suffix",
 			Desc:    "Add a unique suffix to the file name to avoid duplicate file names",
 			Flags:   []string{"unique-suffix"},
-			BoolVal: &flags.uniqueSuffix,
+			BoolVal: &flags.UniqueSuffix,
+		},
+		{
+			Name:       "sequence start",
+			Desc:       "First number of the {n} counter, used in --prefix, --suffix or --override (e.g. {n:3} => 001)",
+			Flags:      []string{"seq-start"},
+			DefaultVal: defaultFlags.SeqStart,
+			IntVal:     &flags.SeqStart,
+		},
+		{
+			Name:       "sequence step",
+			Desc:       "Step between two numbers of the {n} counter",
+			Flags:      []string{"seq-step"},
+			DefaultVal: defaultFlags.SeqStep,
+			IntVal:     &flags.SeqStep,
+		},
+		{
+			Name:       "sequence sort",
+			Desc:       "Order of the {n} counter: name, natural, mtime, date (uses --date-source) or size",
+			Flags:      []string{"seq-sort"},
+			DefaultVal: defaultFlags.SeqSort,
+			StrVal:     &flags.SeqSort,
+		},
+		{
+			Name:    "edit",
+			Desc:    "Edit the file names in $EDITOR, can't be combined with the naming flags",
+			Flags:   []string{"e", "edit"},
+			BoolVal: &flags.edit,
+		},
+		{
+			Name:       "jobs",
+			Desc:       "Number of files probed or hashed at the same time when the name uses media info, {hash} or --dedupe",
+			Flags:      []string{"j", "jobs"},
+			DefaultVal: defaultFlags.Jobs,
+			IntVal:     &flags.Jobs,
+		},
+		{
+			Name:   "state directory",
+			Desc:   "Directory where the undo journals are stored, empty to use $XDG_STATE_HOME/dyno-clis/renamer",
+			Flags:  []string{"state-dir"},
+			StrVal: &flags.stateDir,
+		},
+		{
+			Name:       "on conflict",
+			Desc:       "What to do when the new name is already taken by a file on disk: skip, suffix, overwrite or abort",
+			Flags:      []string{"on-conflict"},
+			DefaultVal: defaultFlags.OnConflict,
+			StrVal:     &flags.OnConflict,
+		},
+		{
+			Name:    "dedupe",
+			Desc:    "Find byte-identical files, the first one in walk order is the original. report: list them, skip: don't rename the duplicates, move: move the duplicates to --dedupe-dir",
+			Flags:   []string{"dedupe"},
+			Example: "report, skip or move",
+			StrVal:  &flags.Dedupe,
+		},
+		{
+			Name:       "dedupe directory",
+			Desc:       "Where --dedupe move puts the duplicates, relative to the path",
+			Flags:      []string{"dedupe-dir"},
+			DefaultVal: defaultFlags.DedupeDir,
+			StrVal:     &flags.DedupeDir,
+		},
+		{
+			Name:    "plan out",
+			Desc:    "Write the sorted rename plan with reasons and conflicts to a JSON or CSV file, run it later with: " + cliName + " " + applyCmd,
+			Example: "plan.json or plan.csv",
+			Flags:   []string{"plan-out"},
+			StrVal:  &flags.planOut,
+		},
+		{
+			Name:    "watch",
+			Desc:    "Keep running and rename the files created in or moved into the path once they stop growing, until Ctrl-C",
+			Flags:   []string{"w", "watch"},
+			BoolVal: &flags.watch,
+		},
+		{
+			Name:       "settle",
+			Desc:       "How long a new file must keep the same size before --watch renames it",
+			Flags:      []string{"settle"},
+			Example:    "500ms or 1m",
+			DefaultVal: defaultFlags.settle,
+			StrVal:     &flags.settle,
 		},
 		{
 			Name:    "dry run",
@@ -139,252 +361,244 @@ func parseFlags() *cliFlags {
 			Flags:   []string{"y", "yes"},
 			BoolVal: &flags.yes,
 		},
-	}
+	}...)
 
-	utils.ParseFlags(flagItems, cliName+" -p /path/to/directory")
+	utils.ParseFlags(flagItems, cliName+" -p /path/to/directory\n  "+cliName+" "+undoCmd+" -h\n  "+cliName+" "+applyCmd+" -h\n  "+cliName+" "+touchCmd+" -h")
 
 	return &flags
 }
 
-func getItemInDir(path string) []os.DirEntry {
-	items, err := os.ReadDir(path)
-	if err != nil {
-		fmt.Println("Failed to read directory", err)
-		return []os.DirEntry{}
+func displayConflicts(conflicts []rename.Conflict) {
+	fmt.Printf("--- Conflicts (%d) ---\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("%s ➡️  %s: %s, %s\n", c.OldPath, c.NewPath, c.Reason, c.Resolution)
 	}
-	return items
 }
 
-func getFileCreatedTime(file os.DirEntry) time.Time {
-	info, _ := file.Info()
-
-	stat := info.Sys().(*syscall.Stat_t)
-	var sec, nsec int64 = 0, 0
-
-	switch {
-	case stat.Birthtimespec.Sec > 0:
-		sec, nsec = stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec
-	case stat.Ctimespec.Sec > 0:
-		sec, nsec = stat.Ctimespec.Sec, stat.Ctimespec.Nsec
-	case stat.Atimespec.Sec > 0:
-		sec, nsec = stat.Atimespec.Sec, stat.Atimespec.Nsec
+func displaySkippedSymlinks(report *rename.Report) {
+	if len(report.SkippedSymlinks) == 0 {
+		return
 	}
 
-	if sec != 0 && nsec != 0 {
-		return time.Unix(sec, nsec)
+	fmt.Printf("--- Skipped symlinks (%d) ---\n", len(report.SkippedSymlinks))
+	for _, skipped := range report.SkippedSymlinks {
+		fmt.Println(skipped)
 	}
-
-	return info.ModTime()
 }
 
-func isHiddenFile(name string) bool {
-	return strings.HasPrefix(name, ".")
-}
-
-func getRenamedName(f os.DirEntry, opts *cliFlags, replacer *replacer) (ignored bool, newName string) {
-	oldName := f.Name()
-
-	// Ignore hidden files
-	if isHiddenFile(oldName) {
-		return true, ""
-	}
-
-	ext := filepath.Ext(oldName)
-	nameWoutExt := strings.TrimSuffix(oldName, ext)
-
-	if opts.exclude != "" {
-		if match := regexp.MustCompile(opts.exclude).MatchString(oldName); match {
-			return true, ""
+// displayDanglingLinks lists the symlinks whose target is renamed, they keep pointing to its
+// old name
+func displayDanglingLinks(plan *rename.Plan, report *rename.Report) {
+	dangling := []string{}
+	for _, r := range plan.Renames {
+		for _, link := range report.SymlinkTargets[r.OldPath] {
+			dangling = append(dangling, fmt.Sprintf("%s ➡️  %s (renamed to %s)", link, r.OldPath, r.NewPath))
 		}
 	}
-
-	if opts.include != "" {
-		if match := regexp.MustCompile(opts.include).MatchString(oldName); !match {
-			return true, ""
-		}
+	if len(dangling) == 0 {
+		return
 	}
 
-	if opts.override != "" {
-		shouldEmpty := opts.override == emptyOverrideFlag && (len(opts.prefix) > 0 || len(opts.suffix) > 0 || opts.uniqueSuffix)
-		if shouldEmpty {
-			nameWoutExt = ""
-		} else {
-			nameWoutExt = opts.override
-		}
-	} else if replacer != nil {
-		nameWoutExt = replacer.regex.ReplaceAllString(nameWoutExt, replacer.replacement)
+	fmt.Printf("--- Symlinks left pointing to the old names (%d) ---\n", len(dangling))
+	for _, link := range dangling {
+		fmt.Println(link)
 	}
+}
 
-	var withSeparator = func(a, b string) string {
-		if a == "" {
-			return b
-		}
-		if b == "" {
-			return a
-		}
-		return a + opts.separator + b
+func displayDuplicates(groups [][]string) {
+	if len(groups) == 0 {
+		return
 	}
-	if opts.createdDate != "" {
-		createdTime := getFileCreatedTime(f)
 
-		if strings.HasPrefix(opts.createdDate, suffixFlag) {
-			nameWoutExt = withSeparator(nameWoutExt, createdTime.Format(utils.ConvertDateLayout(opts.createdDate[len(suffixFlag):])))
-		} else {
-			nameWoutExt = withSeparator(createdTime.Format(utils.ConvertDateLayout(opts.createdDate)), nameWoutExt)
-		}
+	count := 0
+	for _, group := range groups {
+		count += len(group) - 1
 	}
 
-	if opts.detectResolution != "" {
-		w, h := getResolution(f, opts.path)
-		if w > 0 && h > 0 {
-			resolution := fmt.Sprintf("%dx%d", w, h)
-			if opts.detectResolution == suffixFlag {
-				nameWoutExt = withSeparator(nameWoutExt, resolution)
-			} else {
-				nameWoutExt = withSeparator(resolution, nameWoutExt)
-			}
+	fmt.Printf("--- Duplicates (%d) ---\n", count)
+	for _, group := range groups {
+		for _, duplicate := range group[1:] {
+			fmt.Printf("%s = %s\n", duplicate, group[0])
 		}
 	}
+}
 
-	if opts.prefix != "" {
-		nameWoutExt = withSeparator(opts.prefix, nameWoutExt)
+// displayReport prints what the planning found besides the renames
+func displayReport(report *rename.Report, cancelled bool) {
+	for _, err := range report.Errors {
+		fmt.Println(err)
 	}
 
-	if opts.suffix != "" {
-		nameWoutExt = withSeparator(nameWoutExt, opts.suffix)
+	if report.MissingFFProbe > 0 {
+		fmt.Printf("ffprobe is not installed, the media info of %d files could not be read. Please install it to use this feature\n", report.MissingFFProbe)
 	}
 
-	if opts.uniqueSuffix {
-		nameWoutExt = withSeparator(nameWoutExt, utils.GenUniqueStr())
+	if cancelled {
+		fmt.Printf("Cancelled after processing %d of %d files\n", report.Processed, report.Files)
+		return
 	}
 
-	newName = nameWoutExt + ext
-	return oldName == newName, newName
+	displayDuplicates(report.Duplicates)
 }
 
-func getReplaceRegex(replace string) (*replacer, error) {
-	if replace == "" {
-		return nil, nil
+// executeRenames runs the plan and reports the undo journal, exits when a rename fails
+func executeRenames(plan *rename.Plan, opts rename.ApplyOptions) {
+	result, err := rename.Apply(plan, opts)
+	if result == nil {
+		fmt.Println("Nothing has been renamed:", err)
+		os.Exit(1)
 	}
 
-	parts := strings.Split(replace, "=")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid replace format: %s, expected: old=new", replace)
+	var displayJournal = func() {
+		if result.JournalErr != nil {
+			fmt.Println("Failed to save undo journal", result.JournalErr)
+			return
+		}
+		fmt.Printf("Undo with: %s %s --id %s\n", cliName, undoCmd, result.Journal.ID)
 	}
 
-	regex, err := regexp.Compile(parts[0])
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile replace regex: %s, error: %v", parts[0], err)
+		fmt.Println(err)
+		if len(result.Journal.Entries) == 0 {
+			fmt.Println("All renamed files have been rolled back.")
+		} else {
+			displayJournal()
+		}
+		os.Exit(1)
 	}
 
-	return &replacer{regex: regex, replacement: parts[1]}, nil
+	fmt.Printf("🍀 Successfully renamed %d files\n", len(plan.Renames))
+	displayJournal()
 }
 
-func processRename(path string, flags *cliFlags, replacer *replacer) map[string]string {
-	fmt.Println("Processing...")
-
-	items := getItemInDir(path)
-	renamed := make(map[string]string, len(items))
-	shouldSyncProcess := flags.detectResolution == ""
-
-	if shouldSyncProcess {
-		for _, item := range items {
-			if !flags.allowDir && item.IsDir() {
-				continue
-			}
-
-			ignored, newName := getRenamedName(item, flags, replacer)
-			if ignored {
-				continue
-			}
+func Execute() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case undoCmd:
+			executeUndo()
+			return
+		case applyCmd:
+			executeApply()
+			return
+		case touchCmd:
+			executeTouch()
+			return
+		}
+	}
 
-			// Avoid duplicate file names by adding a unique string
-			if _, exists := renamed[newName]; exists {
-				ext := filepath.Ext(newName)
-				nameWoutExt := strings.TrimSuffix(newName, ext)
-				newName = fmt.Sprintf("%s%s%s%s", nameWoutExt, flags.separator, utils.GenUniqueStr(), ext)
-			}
+	flags := parseFlags()
 
-			renamed[newName] = item.Name()
+	path := flags.path
+	if path == "" {
+		currentPath, err := os.Getwd()
+		if err != nil {
+			fmt.Println("Failed to get current directory", err)
+			os.Exit(1)
 		}
-		return renamed
+		path = currentPath
 	}
 
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+	path, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Println("Failed to get absolute path", err)
+		os.Exit(1)
+	}
 
-	const maxWorkers = 100
-	semaphore := make(chan struct{}, maxWorkers)
+	if flags.edit && (flags.Template != "" || len(flags.Replace) > 0 || flags.ReplaceFile != "" || flags.Dedupe != "" || flags.HasNamingOptions() || flags.HasTransformOptions()) {
+		fmt.Println("--edit can't be combined with --template, --replace, --dedupe, the naming flags or the transform flags")
+		os.Exit(1)
+	}
 
-	for _, item := range items {
-		if !flags.allowDir && item.IsDir() {
-			continue
+	if flags.watch {
+		if flags.edit || flags.planOut != "" {
+			fmt.Println("--watch can't be combined with --edit or --plan-out")
+			os.Exit(1)
 		}
+		if err := runWatch(path, flags); err != nil {
+			fmt.Println("Failed to watch", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-		wg.Add(1)
-		go func(item os.DirEntry) {
-			defer wg.Done()
+	var plan *rename.Plan
 
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+	if flags.edit {
+		if plan, err = processEdit(path, flags); err != nil && !errors.Is(err, rename.ErrConflict) {
+			fmt.Println("Failed to edit file names", err)
+			os.Exit(1)
+		}
+	} else {
+		fmt.Println("Processing...")
 
-			ignored, newName := getRenamedName(item, flags, replacer)
-			if ignored {
-				return
-			}
+		progress := newProgress()
+		flags.Progress = progress.update
 
-			// Avoid duplicate file names by adding a unique string
-			mu.Lock()
-			if _, exists := renamed[newName]; exists {
-				ext := filepath.Ext(newName)
-				nameWoutExt := strings.TrimSuffix(newName, ext)
-				newName = fmt.Sprintf("%s%s%s%s", nameWoutExt, flags.separator, utils.GenUniqueStr(), ext)
-			}
-			renamed[newName] = item.Name()
-			mu.Unlock()
-		}(item)
+		// Ctrl-C while probing stops the workers, nothing is renamed
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		plan, err = rename.Build(ctx, path, flags.Options)
+		stop()
+		progress.finish()
 	}
-	wg.Wait()
-
-	return renamed
-}
 
-func Execute() {
-	flags := parseFlags()
+	cancelled := errors.Is(err, context.Canceled)
+	conflictErr := err
+	if !errors.Is(err, rename.ErrConflict) {
+		conflictErr = nil
+	}
 
-	if flags.detectResolution != "" && !isHasFFProbe() {
-		fmt.Println("detect resolution flag is set, but ffprobe is not installed. Please install it to use this feature")
+	if err != nil && !cancelled && conflictErr == nil {
+		fmt.Println("Failed to plan renames", err)
 		os.Exit(1)
 	}
 
-	path := flags.path
-	if path == "" {
-		currentPath, err := os.Getwd()
-		if err != nil {
-			fmt.Println("Failed to get current directory", err)
-			os.Exit(1)
+	report := plan.Report
+	displayReport(report, cancelled)
+
+	var exitCancelled = func() {
+		if cancelled {
+			fmt.Println("Nothing has been renamed.")
+			os.Exit(130)
 		}
-		path = currentPath
 	}
 
-	replacer, err := getReplaceRegex(flags.replace)
-	if err != nil {
-		fmt.Println("Failed to get replace regex", err)
-		os.Exit(1)
+	if len(plan.Renames) == 0 && len(plan.Conflicts) == 0 {
+		exitCancelled()
+		fmt.Println("No files to rename!")
+		return
 	}
 
-	renamed := processRename(path, flags, replacer)
+	plan.Args = os.Args[1:]
 
-	if len(renamed) == 0 {
-		fmt.Println("No files to rename!")
-		return
+	if flags.planOut != "" {
+		if err := rename.WritePlan(flags.planOut, plan); err != nil {
+			fmt.Println("Failed to write plan", err)
+			os.Exit(1)
+		}
+		fmt.Println("Plan written to", flags.planOut)
 	}
 
+	exitCancelled()
+
 	var displaySummary = func() {
 		fmt.Printf("\n--- Summary ---\n")
-		fmt.Printf("Path: %s\n", flags.path)
-		fmt.Printf("Number of files to rename: %d\n", len(renamed))
-
+		fmt.Printf("Path: %s\n", path)
+		if flags.Recursive {
+			fmt.Printf("Recursive: true (max depth: %d)\n", flags.MaxDepth)
+		}
+		if flags.Hidden {
+			fmt.Println("Hidden files: included")
+		}
+		fmt.Printf("Number of files to rename: %d\n", len(plan.Renames))
+		if len(report.Notes) > 0 || len(report.SkippedSymlinks) > 0 {
+			fmt.Printf("Symlink policy: %s\n", flags.Symlinks)
+			displaySkippedSymlinks(report)
+			displayDanglingLinks(plan, report)
+		}
+		if len(plan.Conflicts) > 0 {
+			fmt.Printf("Conflict policy: %s\n", flags.OnConflict)
+			displayConflicts(plan.Conflicts)
+		}
 	}
 
 	// Run in dry run mode
@@ -393,14 +607,41 @@ func Execute() {
 		fmt.Println("--- Dry run mode, will not rename the files ---")
 		fmt.Println("------------------------------------------------")
 
-		for newName, oldName := range renamed {
-			oldPath, newPath := filepath.Join(flags.path, oldName), filepath.Join(flags.path, newName)
-			fmt.Printf("%s ➡️  %s\n", oldPath, newPath)
+		for _, r := range plan.Renames {
+			notes := []string{}
+			if note, ok := report.Notes[r.OldPath]; ok {
+				notes = append(notes, note)
+			}
+			if r.SetModTime != nil {
+				notes = append(notes, "mtime "+r.SetModTime.Format(time.DateTime))
+			}
+
+			if len(notes) > 0 {
+				fmt.Printf("%s ➡️  %s (%s)\n", r.OldPath, r.NewPath, strings.Join(notes, ", "))
+			} else {
+				fmt.Printf("%s ➡️  %s\n", r.OldPath, r.NewPath)
+			}
+		}
+
+		if conflictErr != nil {
+			fmt.Println("--- The run would be aborted:", conflictErr)
 		}
 
 		return
 	}
 
+	if conflictErr != nil {
+		displayConflicts(plan.Conflicts)
+		fmt.Println("Nothing has been renamed:", conflictErr)
+		fmt.Println("Move the existing files first or choose another --on-conflict policy.")
+		os.Exit(1)
+	}
+
+	if len(plan.Renames) == 0 {
+		fmt.Println("No files to rename!")
+		return
+	}
+
 	// Show summary and ask for confirmation
 	if !flags.yes {
 		displaySummary()
@@ -410,15 +651,5 @@ func Execute() {
 		}
 	}
 
-	successCount := 0
-	for newName, oldName := range renamed {
-		oldPath, newPath := filepath.Join(flags.path, oldName), filepath.Join(flags.path, newName)
-		if err := os.Rename(oldPath, newPath); err != nil {
-			fmt.Printf("Failed to rename %s ➡️  %s: %v\n", oldName, newName, err)
-			continue
-		}
-		successCount++
-	}
-
-	fmt.Printf("🍀 Successfully renamed %d files\n", successCount)
+	executeRenames(plan, rename.ApplyOptions{StateDir: flags.stateDir})
 }
@@ -0,0 +1,78 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const progressInterval = 200 * time.Millisecond
+
+// progress reports how many files have been probed, on a single line refreshed in place. It
+// is fed by the Progress option of the rename package
+type progress struct {
+	mu          sync.Mutex
+	total, done int
+	start       time.Time
+	printed     time.Time
+	isPending   bool // a line has been printed without its final newline
+	isTerminal  bool
+}
+
+func newProgress() *progress {
+	return &progress{isTerminal: isTerminal(os.Stdout)}
+}
+
+// line returns e.g. "Processing 120/50000 files, ETA 3m20s", the ETA is based on the average
+// time per file so far
+func (p *progress) line(elapsed time.Duration) string {
+	line := fmt.Sprintf("Processing %d/%d files", p.done, p.total)
+
+	if p.done > 0 && p.done < p.total {
+		eta := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+
+	return line
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// update refreshes the progress line at most every progressInterval, only when the output is
+// a terminal so redirected output stays readable. A batch starts with done = 0
+func (p *progress) update(done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done, p.total = done, total
+	if done == 0 {
+		p.start = time.Now()
+		return
+	}
+
+	if !p.isTerminal || (done < total && time.Since(p.printed) < progressInterval) {
+		return
+	}
+
+	// Clear the end of the previous line, it may have been longer
+	fmt.Printf("\r%s\033[K", p.line(time.Since(p.start)))
+	p.printed, p.isPending = time.Now(), done < total
+	if !p.isPending {
+		fmt.Println()
+	}
+}
+
+// finish ends the progress line of a batch that was cancelled
+func (p *progress) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isPending {
+		fmt.Println()
+		p.isPending = false
+	}
+}
@@ -0,0 +1,24 @@
+package renamer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressLine(t *testing.T) {
+	p := newProgress()
+	p.update(0, 100)
+	if line := p.line(0); line != "Processing 0/100 files" {
+		t.Errorf("line = %q", line)
+	}
+
+	p.update(20, 100)
+	if line := p.line(10 * time.Second); line != "Processing 20/100 files, ETA 40s" {
+		t.Errorf("line = %q", line)
+	}
+
+	p.update(100, 100)
+	if line := p.line(time.Minute); line != "Processing 100/100 files" {
+		t.Errorf("line = %q", line)
+	}
+}
@@ -0,0 +1,184 @@
+package renamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
+)
+
+const touchCmd = "touch"
+
+func parseTouchFlags() *cliFlags {
+	flags := defaultFlags
+	flags.DateSource = rename.DefaultTouchDateSource
+
+	flagItems := append(selectionFlagItems(&flags), []utils.FlagItem{
+		{
+			Name:       "date source",
+			Desc:       "Where the date comes from, tried in order: exif, quicktime, mtime, btime, filename",
+			Example:    "exif,quicktime,filename",
+			Flags:      []string{"date-source"},
+			DefaultVal: flags.DateSource,
+			StrVal:     &flags.DateSource,
+		},
+		{
+			Name:       "jobs",
+			Desc:       "Number of files read at the same time",
+			Flags:      []string{"j", "jobs"},
+			DefaultVal: defaultFlags.Jobs,
+			IntVal:     &flags.Jobs,
+		},
+		{
+			Name:   "state directory",
+			Desc:   "Directory where the undo journals are stored, empty to use $XDG_STATE_HOME/dyno-clis/renamer",
+			Flags:  []string{"state-dir"},
+			StrVal: &flags.stateDir,
+		},
+		{
+			Name:    "dry run",
+			Desc:    "Display the old and new times of the files without changing them",
+			Flags:   []string{"dry-run"},
+			BoolVal: &flags.dryRun,
+		},
+		{
+			Name:    "yes",
+			Desc:    "Skip confirmation prompt",
+			Flags:   []string{"y", "yes"},
+			BoolVal: &flags.yes,
+		},
+	}...)
+
+	utils.ParseFlags(flagItems, cliName+" "+touchCmd+" -p /path/to/directory")
+
+	return &flags
+}
+
+// displayTouch prints the times of the file that change, e.g. "mtime: 2024-01-01 00:00:00 ➡️  2023-05-12 10:15:30"
+func displayTouch(t rename.Touch) {
+	fmt.Printf("%s (%s)\n", t.Path, t.Source)
+
+	var displayTime = func(name string, old, new time.Time) {
+		if !old.Equal(new) {
+			fmt.Printf("  %s: %s ➡️  %s\n", name, old.Format(time.DateTime), new.Format(time.DateTime))
+		}
+	}
+	displayTime("mtime", t.ModTime, t.Date)
+	displayTime("atime", t.AccessTime, t.Date)
+	if t.NewBirthTime != nil {
+		if t.BirthTime != nil {
+			displayTime("btime", *t.BirthTime, *t.NewBirthTime)
+		} else {
+			fmt.Printf("  btime: ➡️  %s\n", t.NewBirthTime.Format(time.DateTime))
+		}
+	}
+}
+
+func runTouch(flags *cliFlags) error {
+	path := flags.path
+	if path == "" {
+		currentPath, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get current directory: %w", err)
+		}
+		path = currentPath
+	}
+
+	fmt.Println("Processing...")
+
+	progress := newProgress()
+	flags.Progress = progress.update
+
+	// Ctrl-C while reading the dates stops the workers, no time is changed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	plan, err := rename.BuildTouch(ctx, path, flags.Options)
+	stop()
+	progress.finish()
+
+	cancelled := errors.Is(err, context.Canceled)
+	if err != nil && !cancelled {
+		return err
+	}
+
+	displayReport(plan.Report, cancelled)
+	if cancelled {
+		fmt.Println("Nothing has been changed.")
+		os.Exit(130)
+	}
+
+	if len(plan.Touches) == 0 {
+		fmt.Printf("No times to change! %d files have no date\n", len(plan.NoDate))
+		return nil
+	}
+
+	fmt.Printf("\n--- Summary ---\n")
+	fmt.Printf("Path: %s\n", plan.Path)
+	if flags.Recursive {
+		fmt.Printf("Recursive: true (max depth: %d)\n", flags.MaxDepth)
+	}
+	fmt.Printf("Date source: %s\n", flags.DateSource)
+	fmt.Printf("Number of files to update: %d\n", len(plan.Touches))
+	if len(plan.NoDate) > 0 {
+		fmt.Printf("Files without a date, kept as they are: %d\n", len(plan.NoDate))
+	}
+	displaySkippedSymlinks(plan.Report)
+
+	if flags.dryRun {
+		fmt.Println("--- Dry run mode, will not change the times ---")
+		fmt.Println("------------------------------------------------")
+		for _, t := range plan.Touches {
+			displayTouch(t)
+		}
+		if len(plan.NoDate) > 0 {
+			fmt.Printf("--- No date (%d) ---\n", len(plan.NoDate))
+			for _, p := range plan.NoDate {
+				fmt.Println(p)
+			}
+		}
+		return nil
+	}
+
+	if !flags.yes && !utils.ConfirmAction("Do you want to continue? (Y/n): ", true) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	// The subcommand has been dropped from os.Args, the journal records the whole command
+	plan.Args = append([]string{touchCmd}, os.Args[1:]...)
+	result, err := rename.ApplyTouch(plan, rename.ApplyOptions{StateDir: flags.stateDir})
+	if result == nil {
+		return err
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Printf("🍀 Successfully updated %d/%d files\n", len(result.Journal.Entries), len(plan.Touches))
+	if len(result.Journal.Entries) > 0 {
+		if result.JournalErr != nil {
+			fmt.Println("Failed to save undo journal", result.JournalErr)
+		} else {
+			fmt.Printf("Undo with: %s %s --id %s\n", cliName, undoCmd, result.Journal.ID)
+		}
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func executeTouch() {
+	// Drop the subcommand so the remaining arguments are parsed as its flags
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+
+	if err := runTouch(parseTouchFlags()); err != nil {
+		fmt.Println("Failed to set the times", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,191 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
+)
+
+const undoCmd = "undo"
+
+type undoFlags struct {
+	id, stateDir             string
+	list, dryRun, yes, force bool
+}
+
+func parseUndoFlags() *undoFlags {
+	flags := &undoFlags{}
+
+	flagItems := []utils.FlagItem{
+		{
+			Name:   "id",
+			Desc:   "Id of the run to undo, empty to undo the last run",
+			Flags:  []string{"id"},
+			StrVal: &flags.id,
+		},
+		{
+			Name:    "list",
+			Desc:    "List the recorded runs",
+			Flags:   []string{"l", "list"},
+			BoolVal: &flags.list,
+		},
+		{
+			Name:   "state directory",
+			Desc:   "Directory where the journals are stored, empty to use $XDG_STATE_HOME/dyno-clis/renamer",
+			Flags:  []string{"state-dir"},
+			StrVal: &flags.stateDir,
+		},
+		{
+			Name:    "force",
+			Desc:    "Also restore files that have been modified since the run",
+			Flags:   []string{"force"},
+			BoolVal: &flags.force,
+		},
+		{
+			Name:    "dry run",
+			Desc:    "Display the files that will be restored without actually renaming them",
+			Flags:   []string{"dry-run"},
+			BoolVal: &flags.dryRun,
+		},
+		{
+			Name:    "yes",
+			Desc:    "Skip confirmation prompt",
+			Flags:   []string{"y", "yes"},
+			BoolVal: &flags.yes,
+		},
+	}
+
+	utils.ParseFlags(flagItems, cliName+" "+undoCmd+" --id <id>")
+
+	return flags
+}
+
+func displayJournals(journals []*rename.Journal) {
+	if len(journals) == 0 {
+		fmt.Println("No recorded runs!")
+		return
+	}
+
+	for _, j := range journals {
+		undone := ""
+		if j.UndoneAt != nil {
+			undone = fmt.Sprintf(" (undone at %s)", j.UndoneAt.Format(time.DateTime))
+		}
+		fmt.Printf("%s  %s  %d files  %s%s\n", j.ID, j.CreatedAt.Format(time.DateTime), len(j.Entries), j.Path, undone)
+		fmt.Printf("    %s %s\n", cliName, strings.Join(j.Args, " "))
+		if len(j.ApplyArgs) > 0 {
+			fmt.Printf("    %s %s\n", cliName, strings.Join(j.ApplyArgs, " "))
+		}
+	}
+}
+
+func displayUndoResults(results []rename.UndoResult) (restoredCount int) {
+	for _, r := range results {
+		switch r.Status {
+		case rename.UndoRestored:
+			restoredCount++
+			if r.Err != nil {
+				fmt.Printf("Restored %s but not its modified time: %v\n", r.Entry.OldPath, r.Err)
+			}
+		case rename.UndoFailed:
+			fmt.Printf("Failed to restore %s ➡️  %s: %v\n", r.Entry.NewPath, r.Entry.OldPath, r.Err)
+		default:
+			fmt.Printf("Skip %s ➡️  %s: %s\n", r.Entry.NewPath, r.Entry.OldPath, r.Status)
+		}
+	}
+	return restoredCount
+}
+
+func runUndo(flags *undoFlags) error {
+	stateDir, err := rename.StateDir(flags.stateDir)
+	if err != nil {
+		return err
+	}
+
+	if flags.list {
+		journals, err := rename.Journals(stateDir)
+		if err != nil {
+			return err
+		}
+		displayJournals(journals)
+		return nil
+	}
+
+	var j *rename.Journal
+	if flags.id != "" {
+		j, err = rename.ReadJournal(stateDir, flags.id)
+	} else {
+		j, err = rename.LastJournal(stateDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if j.UndoneAt != nil {
+		return fmt.Errorf("run %s has already been undone at %s", j.ID, j.UndoneAt.Format(time.DateTime))
+	}
+
+	fmt.Printf("\n--- Undo ---\n")
+	fmt.Printf("Run: %s (%s)\n", j.ID, j.CreatedAt.Format(time.DateTime))
+	fmt.Printf("Path: %s\n", j.Path)
+	fmt.Printf("Command: %s %s\n", cliName, strings.Join(j.Args, " "))
+	if len(j.ApplyArgs) > 0 {
+		fmt.Printf("Applied with: %s %s\n", cliName, strings.Join(j.ApplyArgs, " "))
+	}
+	fmt.Printf("Number of files to restore: %d\n", len(j.Entries))
+
+	opts := rename.UndoOptions{StateDir: stateDir, Force: flags.force, DryRun: flags.dryRun}
+
+	if flags.dryRun {
+		fmt.Println("--- Dry run mode, will not restore the files ---")
+		fmt.Println("------------------------------------------------")
+
+		results, err := rename.Undo(j, opts)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if r.Status == rename.UndoRestored {
+				fmt.Printf("%s ➡️  %s\n", r.Entry.NewPath, r.Entry.OldPath)
+			} else {
+				fmt.Printf("%s ➡️  %s (skip: %s)\n", r.Entry.NewPath, r.Entry.OldPath, r.Status)
+			}
+		}
+		return nil
+	}
+
+	if !flags.yes && !utils.ConfirmAction("Do you want to continue? (Y/n): ", true) {
+		fmt.Println("Operation cancelled.")
+		return nil
+	}
+
+	totalCount := len(j.Entries)
+	results, err := rename.Undo(j, opts)
+	restoredCount := displayUndoResults(results)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🍀 Successfully restored %d/%d files\n", restoredCount, totalCount)
+	switch remaining := len(j.Entries); {
+	case remaining > 0 && j.UndoneAt != nil:
+		fmt.Printf("%d files are missing or have changed since the run and were left as they are, the run is marked as undone\n", remaining)
+	case remaining > 0:
+		fmt.Printf("%d files were skipped, run %s %s --id %s again after fixing them (or with --force for changed files)\n", remaining, cliName, undoCmd, j.ID)
+	}
+	return nil
+}
+
+func executeUndo() {
+	// Drop the subcommand so the remaining arguments are parsed as its flags
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+
+	if err := runUndo(parseUndoFlags()); err != nil {
+		fmt.Println("Failed to undo", err)
+		os.Exit(1)
+	}
+}
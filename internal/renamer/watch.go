@@ -0,0 +1,77 @@
+package renamer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/dynonguyen/dyno-clis/pkg/rename"
+)
+
+// logWatch prints one line per action of the batch, prefixed with the time
+func logWatch(batch rename.WatchBatch, dryRun bool) {
+	now := time.Now().Format(time.DateTime)
+
+	if batch.Plan == nil {
+		fmt.Printf("%s watcher error: %v\n", now, batch.Err)
+		return
+	}
+
+	for _, c := range batch.Plan.Conflicts {
+		fmt.Printf("%s conflict %s ➡️  %s: %s, %s\n", now, c.OldPath, c.NewPath, c.Reason, c.Resolution)
+	}
+
+	action := "renamed"
+	if dryRun {
+		action = "would rename"
+	}
+	if batch.Err == nil {
+		for _, r := range batch.Plan.Renames {
+			fmt.Printf("%s %s %s ➡️  %s\n", now, action, r.OldPath, r.NewPath)
+		}
+	} else {
+		fmt.Printf("%s failed to rename %d files: %v\n", now, len(batch.Plan.Renames), batch.Err)
+	}
+
+	if batch.JournalErr != nil {
+		fmt.Printf("%s failed to save undo journal: %v\n", now, batch.JournalErr)
+	}
+}
+
+// runWatch renames the new files of the path until Ctrl-C, the whole session is undone with a
+// single undo
+func runWatch(path string, flags *cliFlags) error {
+	settle, err := time.ParseDuration(flags.settle)
+	if err != nil || settle <= 0 {
+		return fmt.Errorf("invalid --settle %s, expected a duration, e.g. 500ms or 2s", flags.settle)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var journal *rename.Journal
+	opts := rename.WatchOptions{
+		StateDir: flags.stateDir,
+		Args:     os.Args[1:],
+		Settle:   settle,
+		DryRun:   flags.dryRun,
+		OnBatch: func(batch rename.WatchBatch) {
+			logWatch(batch, flags.dryRun)
+			if batch.Journal != nil {
+				journal = batch.Journal
+			}
+		},
+	}
+
+	fmt.Printf("%s watching %s for new files, press Ctrl-C to stop\n", time.Now().Format(time.DateTime), path)
+	if err := rename.Watch(ctx, path, flags.Options, opts); err != nil {
+		return err
+	}
+
+	if journal != nil && len(journal.Entries) > 0 {
+		fmt.Printf("\nRenamed %d files, undo with: %s %s --id %s\n", len(journal.Entries), cliName, undoCmd, journal.ID)
+	}
+	return nil
+}
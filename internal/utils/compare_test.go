@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "IMG_2.jpg", b: "IMG_10.jpg", expected: true},
+		{a: "IMG_10.jpg", b: "IMG_2.jpg", expected: false},
+		{a: "a.jpg", b: "b.jpg", expected: true},
+		{a: "IMG_02.jpg", b: "IMG_2.jpg", expected: false},
+		{a: "IMG_2.jpg", b: "IMG_02.jpg", expected: true},
+		{a: "IMG_1", b: "IMG_1.jpg", expected: true},
+		{a: "file9b", b: "file9a", expected: false},
+		{a: "same", b: "same", expected: false},
+	}
+
+	for _, test := range tests {
+		if result := NaturalLess(test.a, test.b); result != test.expected {
+			t.Errorf("NaturalLess(%s, %s) = %v, expected %v", test.a, test.b, result, test.expected)
+		}
+	}
+}
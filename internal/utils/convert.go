@@ -1,20 +1,267 @@
 package utils
 
-import "strings"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
-var layoutMapping = map[string]string{
-	"Y": "2006",
-	"M": "01",
-	"D": "02",
-	"h": "15",
-	"m": "04",
-	"s": "05",
-	"f": "000",
+// dateField renders a part of a date, e.g. its 2 digits month
+type dateField func(date time.Time) string
+
+func pad(n, width int) string {
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+func hour12(date time.Time) int {
+	if h := date.Hour() % 12; h != 0 {
+		return h
+	}
+	return 12
+}
+
+func isoYear(date time.Time) int {
+	year, _ := date.ISOWeek()
+	return year
+}
+
+func isoWeek(date time.Time) int {
+	_, week := date.ISOWeek()
+	return week
+}
+
+func isoWeekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+// Tokens of the date formats, a run of the same letter must be one of them: "DDD" is not
+// read as "DD" then "D"
+var dateTokens = map[string]dateField{
+	"YYYY": func(d time.Time) string { return pad(d.Year(), 4) },
+	"YY":   func(d time.Time) string { return pad(d.Year()%100, 2) },
+	"GGGG": func(d time.Time) string { return pad(isoYear(d), 4) },
+	"MMMM": func(d time.Time) string { return d.Month().String() },
+	"MMM":  func(d time.Time) string { return d.Month().String()[:3] },
+	"MM":   func(d time.Time) string { return pad(int(d.Month()), 2) },
+	"M":    func(d time.Time) string { return strconv.Itoa(int(d.Month())) },
+	"DDDD": func(d time.Time) string { return pad(d.YearDay(), 3) },
+	"DD":   func(d time.Time) string { return pad(d.Day(), 2) },
+	"D":    func(d time.Time) string { return strconv.Itoa(d.Day()) },
+	"dddd": func(d time.Time) string { return d.Weekday().String() },
+	"ddd":  func(d time.Time) string { return d.Weekday().String()[:3] },
+	"E":    func(d time.Time) string { return strconv.Itoa(isoWeekday(d)) },
+	"WW":   func(d time.Time) string { return pad(isoWeek(d), 2) },
+	"W":    func(d time.Time) string { return strconv.Itoa(isoWeek(d)) },
+	"HH":   func(d time.Time) string { return pad(d.Hour(), 2) },
+	"H":    func(d time.Time) string { return strconv.Itoa(d.Hour()) },
+	"hh":   func(d time.Time) string { return pad(hour12(d), 2) },
+	"h":    func(d time.Time) string { return strconv.Itoa(hour12(d)) },
+	"mm":   func(d time.Time) string { return pad(d.Minute(), 2) },
+	"m":    func(d time.Time) string { return strconv.Itoa(d.Minute()) },
+	"ss":   func(d time.Time) string { return pad(d.Second(), 2) },
+	"s":    func(d time.Time) string { return strconv.Itoa(d.Second()) },
+	"SSS":  func(d time.Time) string { return pad(d.Nanosecond()/int(time.Millisecond), 3) },
+	"A":    func(d time.Time) string { return d.Format("PM") },
+	"a":    func(d time.Time) string { return d.Format("pm") },
+}
+
+// Directives of the strftime formats, %-X drops the leading zeros of the numbers
+var strftimeDirectives = map[byte]dateField{
+	'Y': func(d time.Time) string { return pad(d.Year(), 4) },
+	'y': func(d time.Time) string { return pad(d.Year()%100, 2) },
+	'G': func(d time.Time) string { return pad(isoYear(d), 4) },
+	'm': func(d time.Time) string { return pad(int(d.Month()), 2) },
+	'B': func(d time.Time) string { return d.Month().String() },
+	'b': func(d time.Time) string { return d.Month().String()[:3] },
+	'd': func(d time.Time) string { return pad(d.Day(), 2) },
+	'j': func(d time.Time) string { return pad(d.YearDay(), 3) },
+	'A': func(d time.Time) string { return d.Weekday().String() },
+	'a': func(d time.Time) string { return d.Weekday().String()[:3] },
+	'u': func(d time.Time) string { return strconv.Itoa(isoWeekday(d)) },
+	'V': func(d time.Time) string { return pad(isoWeek(d), 2) },
+	'H': func(d time.Time) string { return pad(d.Hour(), 2) },
+	'I': func(d time.Time) string { return pad(hour12(d), 2) },
+	'M': func(d time.Time) string { return pad(d.Minute(), 2) },
+	'S': func(d time.Time) string { return pad(d.Second(), 2) },
+	'L': func(d time.Time) string { return pad(d.Nanosecond()/int(time.Millisecond), 3) },
+	'f': func(d time.Time) string { return pad(d.Nanosecond()/int(time.Microsecond), 6) },
+	'p': func(d time.Time) string { return d.Format("PM") },
+	'F': func(d time.Time) string { return d.Format("2006-01-02") },
+	'T': func(d time.Time) string { return d.Format("15:04:05") },
+}
+
+// Directives of numbers, the only ones %- applies to
+const numericDirectives = "YyGmdjuVHIMSLf"
+
+// dateFormatPart is either a literal text or a field of the date
+type dateFormatPart struct {
+	literal string
+	field   dateField
+}
+
+// DateFormat is a parsed date format, see ParseDateFormat
+type DateFormat struct {
+	parts []dateFormatPart
+}
+
+// ParseDateFormat parses a date format made of tokens, e.g. "YYYY-MM-DD HH.mm.ss" or
+// "ddd, D MMMM", or of strftime directives when it has a %, e.g. "%Y-%m-%d_%H%M%S".
+// Text between single quotes is kept as it is, e.g. "YYYY'W'WW", two single quotes are a quote.
+// Letters outside the quotes must be tokens
+func ParseDateFormat(format string) (DateFormat, error) {
+	if strings.Contains(format, "%") {
+		return parseStrftime(format)
+	}
+
+	f := DateFormat{}
+	literal := strings.Builder{}
+
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			f.parts = append(f.parts, dateFormatPart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(format); {
+		c := format[i]
+
+		if c == '\'' {
+			if i+1 < len(format) && format[i+1] == '\'' {
+				literal.WriteByte('\'')
+				i += 2
+				continue
+			}
+
+			start := i
+			for i++; ; i++ {
+				if i >= len(format) {
+					return DateFormat{}, fmt.Errorf("invalid date format: %s, missing ' for the text at %d", format, start)
+				}
+				if format[i] != '\'' {
+					literal.WriteByte(format[i])
+					continue
+				}
+				// '' inside the quotes is a quote too
+				if i+1 < len(format) && format[i+1] == '\'' {
+					literal.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			i++
+			continue
+		}
+
+		if !isLetter(c) {
+			literal.WriteByte(c)
+			i++
+			continue
+		}
+
+		run := i + 1
+		for run < len(format) && format[run] == c {
+			run++
+		}
+
+		field, ok := dateTokens[format[i:run]]
+		if !ok {
+			return DateFormat{}, fmt.Errorf("invalid date format: %s, unknown token %s at %d (quote the text, e.g. 'T')", format, format[i:run], i)
+		}
+		flushLiteral()
+		f.parts = append(f.parts, dateFormatPart{field: field})
+		i = run
+	}
+	flushLiteral()
+
+	return f, nil
+}
+
+func parseStrftime(format string) (DateFormat, error) {
+	f := DateFormat{}
+	literal := strings.Builder{}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		noPadding := i < len(format) && format[i] == '-'
+		if noPadding {
+			i++
+		}
+		if i >= len(format) {
+			return DateFormat{}, fmt.Errorf("invalid date format: %s, missing directive after %%", format)
+		}
+
+		if format[i] == '%' && !noPadding {
+			literal.WriteByte('%')
+			continue
+		}
+
+		field, ok := strftimeDirectives[format[i]]
+		if !ok {
+			return DateFormat{}, fmt.Errorf("invalid date format: %s, unknown directive %%%c", format, format[i])
+		}
+		if noPadding {
+			if !strings.ContainsRune(numericDirectives, rune(format[i])) {
+				return DateFormat{}, fmt.Errorf("invalid date format: %s, %%-%c is not a number", format, format[i])
+			}
+			field = trimZeros(field)
+		}
+
+		if literal.Len() > 0 {
+			f.parts = append(f.parts, dateFormatPart{literal: literal.String()})
+			literal.Reset()
+		}
+		f.parts = append(f.parts, dateFormatPart{field: field})
+	}
+	if literal.Len() > 0 {
+		f.parts = append(f.parts, dateFormatPart{literal: literal.String()})
+	}
+
+	return f, nil
+}
+
+// trimZeros drops the leading zeros of a numeric field, "0" is kept
+func trimZeros(field dateField) dateField {
+	return func(date time.Time) string {
+		if s := strings.TrimLeft(field(date), "0"); s != "" {
+			return s
+		}
+		return "0"
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Format returns the date in the format
+func (f DateFormat) Format(date time.Time) string {
+	s := strings.Builder{}
+	for _, part := range f.parts {
+		if part.field != nil {
+			s.WriteString(part.field(date))
+		} else {
+			s.WriteString(part.literal)
+		}
+	}
+	return s.String()
 }
 
-func ConvertDateLayout(format string) string {
-	for old, new := range layoutMapping {
-		format = strings.ReplaceAll(format, old, new)
+// FormatDate returns the date in the format, see ParseDateFormat
+func FormatDate(date time.Time, format string) (string, error) {
+	f, err := ParseDateFormat(format)
+	if err != nil {
+		return "", err
 	}
-	return format
+	return f.Format(date), nil
 }
@@ -2,35 +2,78 @@ package utils
 
 import (
 	"testing"
+	"time"
 )
 
-func TestConvertDateLayout(t *testing.T) {
+func TestFormatDate(t *testing.T) {
+	// Friday, ISO week 1 of 2027
+	date := time.Date(2027, 1, 1, 9, 5, 7, 42_123_000, time.UTC)
+	afternoon := time.Date(2024, 5, 12, 15, 30, 0, 0, time.UTC)
+
 	tests := []struct {
+		date     time.Time
 		format   string
 		expected string
 	}{
-		{
-			format:   "Y-M-D",
-			expected: "2006-01-02",
-		},
-		{
-			format:   "Y-M-D h:m:s",
-			expected: "2006-01-02 15:04:05",
-		},
-		{
-			format:   "YMD_hms",
-			expected: "20060102_150405",
-		},
-		{
-			format:   "YMD_hms.f",
-			expected: "20060102_150405.000",
-		},
+		{date: date, format: "YYYY", expected: "2027"},
+		{date: date, format: "YY", expected: "27"},
+		{date: date, format: "GGGG", expected: "2026"},
+		{date: date, format: "MMMM", expected: "January"},
+		{date: date, format: "MMM", expected: "Jan"},
+		{date: date, format: "MM", expected: "01"},
+		{date: date, format: "M", expected: "1"},
+		{date: afternoon, format: "DDDD", expected: "133"},
+		{date: date, format: "DD", expected: "01"},
+		{date: date, format: "D", expected: "1"},
+		{date: date, format: "dddd", expected: "Friday"},
+		{date: date, format: "ddd", expected: "Fri"},
+		{date: date, format: "E", expected: "5"},
+		{date: date, format: "WW", expected: "53"},
+		{date: afternoon, format: "W", expected: "19"},
+		{date: date, format: "HH", expected: "09"},
+		{date: afternoon, format: "H", expected: "15"},
+		{date: afternoon, format: "hh", expected: "03"},
+		{date: date, format: "h", expected: "9"},
+		{date: date, format: "mm", expected: "05"},
+		{date: date, format: "m", expected: "5"},
+		{date: date, format: "ss", expected: "07"},
+		{date: date, format: "s", expected: "7"},
+		{date: date, format: "SSS", expected: "042"},
+		{date: afternoon, format: "A", expected: "PM"},
+		{date: date, format: "a", expected: "am"},
+		{date: date, format: "YYYY-MM-DD", expected: "2027-01-01"},
+		{date: date, format: "YYYYMMDD_HHmmss.SSS", expected: "20270101_090507.042"},
+		{date: date, format: "ddd, D MMMM YYYY", expected: "Fri, 1 January 2027"},
+		{date: date, format: "GGGG-'W'WW", expected: "2026-W53"},
+		{date: date, format: "'Day' D 'of' MMMM", expected: "Day 1 of January"},
+		{date: date, format: "YYYY'MMDD'", expected: "2027MMDD"},
+		{date: date, format: "D''MM", expected: "1'01"},
+		{date: date, format: "'It''s' YYYY", expected: "It's 2027"},
+		{date: date, format: "", expected: ""},
+
+		{date: date, format: "%Y-%m-%d", expected: "2027-01-01"},
+		{date: date, format: "%y %G %B %b %j", expected: "27 2026 January Jan 001"},
+		{date: date, format: "%A %a %u %V", expected: "Friday Fri 5 53"},
+		{date: afternoon, format: "%H %I %M %S %p", expected: "15 03 30 00 PM"},
+		{date: date, format: "%L %f", expected: "042 042123"},
+		{date: date, format: "%F_%T", expected: "2027-01-01_09:05:07"},
+		{date: date, format: "%-m/%-d %-H:%M:%-S", expected: "1/1 9:05:7"},
+		{date: date, format: "100%% %Y", expected: "100% 2027"},
+		{date: date, format: "'%Y' YYYY", expected: "'2027' YYYY"},
 	}
 
 	for _, test := range tests {
-		result := ConvertDateLayout(test.format)
-		if result != test.expected {
-			t.Errorf("ConvertDateLayout(%s) = %s, expected %s", test.format, result, test.expected)
+		result, err := FormatDate(test.date, test.format)
+		if err != nil || result != test.expected {
+			t.Errorf("FormatDate(%s) = %s, %v, expected %s", test.format, result, err, test.expected)
+		}
+	}
+}
+
+func TestParseDateFormatError(t *testing.T) {
+	for _, format := range []string{"Y-M-D", "YYYY-MM-DDTHH", "dd", "DDD", "YYYYY", "YYY", "MMMMM", "SS", "hhh", "YYYY 'W", "%Q", "%", "%-", "%-B", "%Y %"} {
+		if _, err := ParseDateFormat(format); err == nil {
+			t.Errorf("ParseDateFormat(%s) = nil, expected an error", format)
 		}
 	}
 }
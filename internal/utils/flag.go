@@ -13,11 +13,28 @@ type FlagItem struct {
 	StrVal      *string
 	BoolVal     *bool
 	IntVal      *int
-	SliceStrVal []string
+	SliceStrVal *[]string
 	DefaultVal  any
 	Example     string
 }
 
+// stringSlice collects every value of a repeatable flag, e.g. --replace a=b --replace c=d
+type stringSlice struct {
+	values *[]string
+}
+
+func (s *stringSlice) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ", ")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s.values = append(*s.values, value)
+	return nil
+}
+
 func (fi *FlagItem) parseFlag() {
 	for _, flagName := range fi.Flags {
 		switch {
@@ -39,6 +56,8 @@ func (fi *FlagItem) parseFlag() {
 				dVal = fi.DefaultVal.(int)
 			}
 			flag.IntVar(fi.IntVal, flagName, dVal, fi.Desc)
+		case fi.SliceStrVal != nil:
+			flag.Var(&stringSlice{values: fi.SliceStrVal}, flagName, fi.Desc)
 		}
 	}
 }
@@ -75,6 +94,9 @@ func ParseFlags(items []FlagItem, example string) {
 			if item.Required {
 				required = " (required)"
 			}
+			if item.SliceStrVal != nil {
+				required += " (repeatable)"
+			}
 
 			fmt.Printf("  %s: %s%s%s%s\n", strings.Join(keys, ", "), item.Desc, defaultVal, required, flagExample)
 		}
@@ -0,0 +1,135 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ApplyOptions configures Apply and ApplyTouch
+type ApplyOptions struct {
+	// Where the undo journal is saved, empty for the default StateDir
+	StateDir string
+	// Command line that applies a saved plan, recorded in the journal next to Plan.Args
+	Args []string
+}
+
+// Result is what Apply did
+type Result struct {
+	// The renames that were applied, saved in the state directory so they can be undone. Nil
+	// when the plan was rejected before renaming anything
+	Journal *Journal
+	// Set when the journal could not be saved, the renames are kept
+	JournalErr error
+}
+
+// FindConflicts returns the planned targets that are taken on disk, except the ones the plan
+// chose to overwrite
+func (p *Plan) FindConflicts() ([]Conflict, error) {
+	renamed, err := p.renamed()
+	if err != nil {
+		return nil, err
+	}
+
+	overwritten := map[string]bool{}
+	for _, c := range p.Conflicts {
+		if c.Resolution == resolutionOverwritten {
+			overwritten[c.NewPath] = true
+		}
+	}
+
+	conflicts := []Conflict{}
+	for _, c := range findConflicts(renamed) {
+		if !overwritten[c.NewPath] {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts, nil
+}
+
+// Apply runs the plan, after checking that its source files are unchanged and that no target
+// has been taken in the meantime. The files are renamed in a safe order, when a rename fails
+// the renames already done are rolled back and the error is returned with the renames that
+// could not be reverted in the journal
+func Apply(plan *Plan, opts ApplyOptions) (*Result, error) {
+	renamed, err := plan.check()
+	if err != nil {
+		return nil, err
+	}
+
+	j := newJournal(plan.Path, plan.Args)
+	j.ApplyArgs = opts.Args
+	err = plan.apply(renamed, j)
+
+	result := &Result{Journal: j}
+	if len(j.Entries) > 0 {
+		result.JournalErr = j.saveTo(opts.StateDir)
+	}
+
+	return result, err
+}
+
+// check returns the renames of the plan, new path => old path, once they are known to be
+// runnable: valid, with unchanged sources and free targets
+func (p *Plan) check() (map[string]string, error) {
+	renamed, err := p.renamed()
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := p.CheckSources(); len(problems) > 0 {
+		return nil, fmt.Errorf("%d source files changed since the plan was written, write a new plan", len(problems))
+	}
+
+	conflicts, err := p.FindConflicts()
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%d %w", len(conflicts), ErrConflict)
+	}
+
+	return renamed, nil
+}
+
+// apply runs the checked renames and adds the ones that are done to the journal
+func (p *Plan) apply(renamed map[string]string, j *Journal) error {
+	applied, createdDirs, err := applyOps(orderRenames(renamed))
+
+	oldModTimes := map[string]time.Time{}
+	if err == nil {
+		oldModTimes, err = setModTimes(p.Renames)
+	}
+
+	j.Dirs = append(j.Dirs, createdDirs...)
+	for _, op := range applied {
+		j.add(op.oldPath, op.newPath)
+		if modTime, ok := oldModTimes[op.newPath]; ok {
+			j.Entries[len(j.Entries)-1].OldModTime = &modTime
+		}
+	}
+
+	return err
+}
+
+// setModTimes gives the renamed files their planned mod time and returns the mod time they had,
+// by new path. The files whose mod time could not be set keep their rename
+func setModTimes(renames []Rename) (map[string]time.Time, error) {
+	oldModTimes := map[string]time.Time{}
+	errs := []error{}
+
+	for _, r := range renames {
+		if r.SetModTime == nil {
+			continue
+		}
+		// The zero access time is left unchanged
+		if err := os.Chtimes(r.NewPath, time.Time{}, *r.SetModTime); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set the mod time of %s: %w", r.NewPath, err))
+			continue
+		}
+		oldModTimes[r.NewPath] = r.ModTime
+	}
+
+	return oldModTimes, errors.Join(errs...)
+}
@@ -0,0 +1,351 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+)
+
+// Report is what Build found besides the renames, it is not saved in plan files
+type Report struct {
+	// Number of files to rename, and how many of them were processed before the context was
+	// cancelled
+	Files, Processed int
+	// Old path => how a symlink got in the plan
+	Notes map[string]string
+	// "path: reason" of the symlinks that are not renamed
+	SkippedSymlinks []string
+	// Old path => the symlinks pointing to it with the target policy. They are not updated,
+	// renaming the file leaves them dangling
+	SymlinkTargets map[string][]string
+	// Byte-identical files found by Dedupe, the first path of each group is the original
+	Duplicates [][]string
+	// Number of videos whose media info could not be read because ffprobe is not installed
+	MissingFFProbe int
+	// Directories that could not be read, they are left out of the plan
+	Errors []error
+}
+
+// dirItem is an entry found while scanning, dir is the directory that contains it
+type dirItem struct {
+	dir   string
+	entry os.DirEntry
+	seq   int // Sequence number, only set when a {n} token is used
+}
+
+func getItemInDir(path string) ([]dirItem, []error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return []dirItem{}, []error{fmt.Errorf("failed to read directory: %w", err)}
+	}
+
+	items := make([]dirItem, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, dirItem{dir: path, entry: entry})
+	}
+	return items, nil
+}
+
+// getItemInTree walks the whole tree under root, maxDepth <= 0 means no limit.
+// Entries directly inside root are at depth 1
+func getItemInTree(root string, maxDepth int, includeHidden bool) ([]dirItem, []error) {
+	items := []dirItem{}
+	errs := []error{}
+
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read directory: %w", err))
+			return nil
+		}
+
+		if path == root {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		depth := strings.Count(rel, string(filepath.Separator)) + 1
+
+		// SkipDir on a file would skip the rest of its directory
+		if maxDepth > 0 && depth > maxDepth {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		items = append(items, dirItem{dir: filepath.Dir(path), entry: d})
+
+		// Don't descend into hidden directories, the same way hidden files are ignored
+		if d.IsDir() && ((!includeHidden && isHiddenFile(d.Name())) || (maxDepth > 0 && depth == maxDepth)) {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to walk directory: %w", err))
+	}
+
+	return items, errs
+}
+
+func getItems(path string, opts *config) ([]dirItem, []error) {
+	if opts.Recursive {
+		return getItemInTree(path, opts.MaxDepth, opts.Hidden)
+	}
+	return getItemInDir(path)
+}
+
+func isHiddenFile(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// isFilteredOut tells whether the entry is skipped before any renaming happens
+func isFilteredOut(item dirItem, opts *config) bool {
+	name := item.entry.Name()
+
+	if !opts.AllowDir && item.entry.IsDir() {
+		return true
+	}
+
+	// Ignore hidden files unless Hidden
+	if !opts.Hidden && isHiddenFile(name) {
+		return true
+	}
+
+	return !opts.filter.match(item)
+}
+
+// getTargetItems returns the items to rename: the entries of the path that pass the filters,
+// with the symlink policy applied. The report has the symlinks and the unreadable directories
+func getTargetItems(path string, opts *config) ([]dirItem, *Report) {
+	items, errs := getItems(path, opts)
+	items, symlinks := applySymlinkPolicy(path, filterItems(items, opts), opts)
+
+	return items, &Report{
+		Files:           len(items),
+		Notes:           symlinks.notes,
+		SkippedSymlinks: symlinks.skipped,
+		SymlinkTargets:  symlinks.links,
+		Duplicates:      [][]string{},
+		Errors:          errs,
+	}
+}
+
+// onlyItems keeps the items whose path is in only
+func onlyItems(items []dirItem, only map[string]bool) []dirItem {
+	kept := make([]dirItem, 0, len(only))
+	for _, item := range items {
+		if only[filepath.Join(item.dir, item.entry.Name())] {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+func filterItems(items []dirItem, opts *config) []dirItem {
+	filtered := make([]dirItem, 0, len(items))
+	for _, item := range items {
+		if !isFilteredOut(item, opts) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func isPathSeparator(r rune) bool {
+	return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r))
+}
+
+// cleanNewName drops the empty, . and .. segments of a name with path separators, so a file
+// can only move below its own directory
+func cleanNewName(name string) string {
+	segments := []string{}
+	for _, segment := range strings.FieldsFunc(name, isPathSeparator) {
+		if segment != "." && segment != ".." {
+			segments = append(segments, segment)
+		}
+	}
+	return filepath.Join(segments...)
+}
+
+// getRenamedName returns the new name of the item, relative to its directory. Path separators
+// in the template move the file into sub directories, the transforms only apply to the file
+// name. An empty name keeps the old one
+func getRenamedName(item dirItem, opts *config, media *mediaCache) (ignored bool, newName string) {
+	oldName := item.entry.Name()
+	newName = cleanNewName(opts.nameTemplate.render(&templateContext{item: item, opts: opts, replacer: opts.replacer, media: media}))
+
+	dir, base := filepath.Split(newName)
+	if newName = filepath.Join(dir, applyTransforms(base, &opts.Options)); newName == "" {
+		newName = oldName
+	}
+	return oldName == newName, newName
+}
+
+// withUniqueSuffix avoids duplicate file names by adding a unique string
+func withUniqueSuffix(newName, separator string) string {
+	ext := filepath.Ext(newName)
+	nameWoutExt := strings.TrimSuffix(newName, ext)
+	return fmt.Sprintf("%s%s%s%s", nameWoutExt, separator, utils.GenUniqueStr(), ext)
+}
+
+// renderNames returns the new name of each item, in the order of the items. Templates that
+// read the media or the content run on a pool of Jobs workers. When ctx is cancelled, the
+// items that were not processed yet are left out: done tells which ones have a name
+func renderNames(ctx context.Context, items []dirItem, opts *config, media *mediaCache) (names []string, done []bool) {
+	names = make([]string, len(items))
+
+	if !opts.nameTemplate.needsWorkers() {
+		done = make([]bool, len(items))
+		for i, item := range items {
+			if ctx.Err() != nil {
+				break
+			}
+			_, names[i] = getRenamedName(item, opts, media)
+			done[i] = true
+		}
+		return names, done
+	}
+
+	done = runPool(ctx, len(items), opts.Jobs, opts.Progress, func(i int) {
+		_, names[i] = getRenamedName(items[i], opts, media)
+	})
+
+	return names, done
+}
+
+// planRenames returns the planned renames as new path => old path. Because the keys are full
+// paths, duplicate names are only detected between files of the same directory, the first
+// item in walk order keeps the name. When ctx is cancelled before every item was processed,
+// the renames only have the processed items. A non-nil only restricts the plan to these paths
+func (c *config) planRenames(ctx context.Context, path string, only map[string]bool) (map[string]string, *Report) {
+	template := c.nameTemplate
+	media := newMediaCache(template.has(tokenDuration) || template.has(tokenFPS) || template.has(tokenCodec))
+
+	items, report := getTargetItems(path, c)
+	if only != nil {
+		items = onlyItems(items, only)
+		report.Files = len(items)
+	}
+	renamed := make(map[string]string, len(items))
+
+	// Duplicates are set aside before the counter is assigned, so it has no gaps
+	if c.Dedupe != "" {
+		groups, cancelled := findDuplicates(ctx, items, c.Jobs, c.Progress, media)
+		if cancelled {
+			return renamed, report
+		}
+
+		report.Duplicates = duplicatePaths(items, groups)
+		if c.Dedupe != dedupeReport {
+			items = setDuplicatesAside(path, items, groups, c, renamed)
+			report.Files = len(items)
+		}
+	}
+
+	if template.has(tokenSeq) {
+		assignSequence(items, c)
+	}
+
+	names, done := renderNames(ctx, items, c, media)
+	report.MissingFFProbe = media.missingFFProbe
+
+	for i, item := range items {
+		if !done[i] {
+			continue
+		}
+		report.Processed++
+
+		if names[i] == item.entry.Name() {
+			continue
+		}
+
+		newPath := filepath.Join(item.dir, names[i])
+		if _, exists := renamed[newPath]; exists {
+			newPath = filepath.Join(item.dir, withUniqueSuffix(names[i], c.Separator))
+		}
+		renamed[newPath] = filepath.Join(item.dir, item.entry.Name())
+	}
+
+	return renamed, report
+}
+
+// Build plans the renames of the files in dir. The conflict policy is applied to the plan,
+// with OnConflict abort the plan is returned along with an error wrapping ErrConflict. When
+// ctx is cancelled, the plan of the files processed so far is returned with ctx.Err()
+func Build(ctx context.Context, dir string, opts Options) (*Plan, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Journals are replayed from anywhere, so every planned path must be absolute
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+
+	renamed, report := c.planRenames(ctx, dir, nil)
+	plan, err := newPlan(dir, renamed, c)
+	plan.Report = report
+
+	if ctx.Err() != nil {
+		return plan, ctx.Err()
+	}
+	return plan, err
+}
+
+// Files returns the paths Build would rename in dir, sorted, without planning their names. It
+// lets the caller pick the new names itself, e.g. in an editor, then plan them with NewPlan
+func Files(dir string, opts Options) ([]string, *Report, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, nil, err
+	}
+
+	items, report := getTargetItems(dir, c)
+	paths := make([]string, 0, len(items))
+	for _, item := range items {
+		paths = append(paths, filepath.Join(item.dir, item.entry.Name()))
+	}
+	sort.Strings(paths)
+
+	return paths, report, nil
+}
+
+// NewPlan builds the plan of the given renames, new path => old path, and applies the
+// conflict policy of opts to it the same way Build does
+func NewPlan(dir string, renames map[string]string, opts Options) (*Plan, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+
+	for newPath, oldPath := range renames {
+		if !filepath.IsAbs(oldPath) || !filepath.IsAbs(newPath) {
+			return nil, fmt.Errorf("%s ➡️  %s: paths must be absolute", oldPath, newPath)
+		}
+	}
+
+	// The conflict policy edits the renames, the caller's map is left as is
+	renamed := make(map[string]string, len(renames))
+	for newPath, oldPath := range renames {
+		renamed[newPath] = oldPath
+	}
+
+	return newPlan(dir, renamed, c)
+}
@@ -0,0 +1,373 @@
+package rename
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildWorkers(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 20 {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("photo_%02d.png", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		png.Encode(f, image.NewGray(image.Rect(0, 0, 4, 2)))
+		f.Close()
+	}
+
+	opts := DefaultOptions()
+	opts.Template = "{res}"
+	opts.Jobs = 4
+
+	// Every file gets the same name, the first one in walk order keeps it
+	for range 5 {
+		plan, err := Build(context.Background(), dir, opts)
+		if err != nil || len(plan.Renames) != 20 {
+			t.Fatalf("err = %v, renames = %d, want 20", err, len(plan.Renames))
+		}
+		if r := plan.Renames[0]; r.OldPath != filepath.Join(dir, "photo_00.png") || r.NewPath != filepath.Join(dir, "4x2.png") {
+			t.Errorf("photo_00.png is renamed to %s", r.NewPath)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if plan, err := Build(ctx, dir, opts); !errors.Is(err, context.Canceled) || len(plan.Renames) != 0 || plan.Report.Processed != 0 {
+		t.Errorf("err = %v, renames = %d, want nothing processed", err, len(plan.Renames))
+	}
+}
+
+func TestBuildApplyUndo(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(t.TempDir(), "state")
+	writeFiles(t, dir, "a.jpg", "b.jpg", "P_b.jpg")
+
+	opts := DefaultOptions()
+	opts.Prefix, opts.Exclude = "P", "^P_"
+
+	plan, err := Build(context.Background(), dir, opts)
+	if !errors.Is(err, ErrConflict) || len(plan.Conflicts) != 1 {
+		t.Fatalf("err = %v, conflicts = %v, want the P_b.jpg conflict", err, plan.Conflicts)
+	}
+
+	opts.OnConflict = conflictSkip
+	if plan, err = Build(context.Background(), dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renames) != 1 || plan.Renames[0].NewPath != filepath.Join(dir, "P_a.jpg") {
+		t.Fatalf("renames = %v, want a.jpg => P_a.jpg", plan.Renames)
+	}
+
+	result, err := Apply(plan, ApplyOptions{StateDir: stateDir})
+	if err != nil || result.JournalErr != nil {
+		t.Fatal(err, result.JournalErr)
+	}
+	if readContent(dir, "P_a.jpg") != "a.jpg" {
+		t.Errorf("a.jpg was not renamed")
+	}
+
+	// The plan has been run, its sources are gone
+	if _, err := Apply(plan, ApplyOptions{StateDir: stateDir}); err == nil {
+		t.Errorf("expected the second apply to fail")
+	}
+
+	j, err := LastJournal(stateDir)
+	if err != nil || j.ID != result.Journal.ID {
+		t.Fatalf("last journal = %v, %v, want %s", j, err, result.Journal.ID)
+	}
+
+	results, err := Undo(j, UndoOptions{StateDir: stateDir})
+	if err != nil || len(results) != 1 || results[0].Status != UndoRestored {
+		t.Fatalf("undo = %v, %v", results, err)
+	}
+	if readContent(dir, "a.jpg") != "a.jpg" {
+		t.Errorf("a.jpg was not restored")
+	}
+	if _, err := LastJournal(stateDir); err == nil {
+		t.Errorf("expected no run left to undo")
+	}
+}
+
+func TestApplyOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.jpg", "P_a.jpg")
+
+	opts := DefaultOptions()
+	opts.Prefix, opts.Exclude, opts.OnConflict = "P", "^P_", conflictOverwrite
+
+	plan, err := Build(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Apply(plan, ApplyOptions{StateDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	if readContent(dir, "P_a.jpg") != "a.jpg" {
+		t.Errorf("P_a.jpg was not overwritten")
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	cases := map[string]func(o *Options){
+		"template and prefix": func(o *Options) { o.Template, o.Prefix = "{name}", "P" },
+		"aspect ratio":        func(o *Options) { o.AspectRatio = "x" },
+		"case":                func(o *Options) { o.CaseStyle = "shout" },
+		"conflict policy":     func(o *Options) { o.OnConflict = "ask" },
+		"symlinks":            func(o *Options) { o.Symlinks = "follow" },
+		"dedupe":              func(o *Options) { o.Dedupe = "delete" },
+		"sequence sort":       func(o *Options) { o.SeqSort = "random" },
+		"date source":         func(o *Options) { o.DateSource = "ctime" },
+		"filter":              func(o *Options) { o.MinSize = "big" },
+		"replace":             func(o *Options) { o.Replace = []string{"nope"} },
+	}
+
+	for name, edit := range cases {
+		opts := DefaultOptions()
+		edit(&opts)
+		if _, err := Build(context.Background(), t.TempDir(), opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCleanNewName(t *testing.T) {
+	testCases := map[string]string{
+		"a.jpg":           "a.jpg",
+		"2024/05/a.jpg":   filepath.Join("2024", "05", "a.jpg"),
+		"/2024//a.jpg":    filepath.Join("2024", "a.jpg"),
+		"../../a.jpg":     "a.jpg",
+		"2024/./../a.jpg": filepath.Join("2024", "a.jpg"),
+		"/":               "",
+	}
+
+	for input, expected := range testCases {
+		if actual := cleanNewName(input); actual != expected {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", input, expected, actual)
+		}
+	}
+}
+
+func TestBuildFolderLayout(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeFiles(t, dir, "a.JPG", "b.jpg", "notes")
+
+	opts := DefaultOptions()
+	opts.Template = "{parent}/{date:YYYY}/{name}"
+	opts.DateSource = "filename"
+	opts.CaseStyle = caseUpper
+	os.Rename(filepath.Join(dir, "a.JPG"), filepath.Join(dir, "IMG_20240512.JPG"))
+
+	plan, err := Build(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b.jpg has no date, the empty folder is dropped. The transforms only apply to the file name
+	parent := filepath.Base(dir)
+	expected := map[string]string{
+		filepath.Join(dir, "IMG_20240512.JPG"): filepath.Join(dir, parent, "2024", "IMG_20240512.JPG"),
+		filepath.Join(dir, "b.jpg"):            filepath.Join(dir, parent, "B.jpg"),
+		filepath.Join(dir, "notes"):            filepath.Join(dir, parent, "NOTES"),
+	}
+	if len(plan.Renames) != len(expected) {
+		t.Fatalf("renames = %v", plan.Renames)
+	}
+	for _, r := range plan.Renames {
+		if expected[r.OldPath] != r.NewPath {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", r.OldPath, expected[r.OldPath], r.NewPath)
+		}
+	}
+
+	result, err := Apply(plan, ApplyOptions{StateDir: stateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readContent(filepath.Join(dir, parent, "2024"), "IMG_20240512.JPG") != "a.JPG" {
+		t.Errorf("FAIL => Expected: the photo moved to %s/2024", parent)
+	}
+
+	if _, err := Undo(result.Journal, UndoOptions{StateDir: stateDir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, parent)); !os.IsNotExist(err) {
+		t.Errorf("FAIL => Expected: the created directories removed by undo - Actual: %v", err)
+	}
+}
+
+func TestBuildFolderLayoutConflict(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "IMG_20240512.jpg", "2024")
+
+	opts := DefaultOptions()
+	opts.Template = "{date:YYYY}/{name}"
+	opts.DateSource = "filename"
+
+	if _, err := Build(context.Background(), dir, opts); !errors.Is(err, ErrConflict) {
+		t.Fatalf("err = %v, want a conflict with the 2024 file", err)
+	}
+
+	// The 2024 file is in the way of the photo directory, suffixing can't solve it
+	opts.OnConflict = conflictSuffix
+	plan, err := Build(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renames) != 0 || len(plan.Conflicts) != 1 || plan.Conflicts[0].Resolution != "skipped" {
+		t.Errorf("renames = %v, conflicts = %v, want the photo skipped", plan.Renames, plan.Conflicts)
+	}
+}
+
+func TestBuildSetModTime(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeFiles(t, dir, "IMG_20230512_101530.jpg", "notes.txt")
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	os.Chtimes(filepath.Join(dir, "IMG_20230512_101530.jpg"), mtime, mtime)
+
+	opts := DefaultOptions()
+	opts.Prefix, opts.SetModTime = "P", true
+
+	plan, err := Build(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renames) != 2 || plan.Renames[0].SetModTime == nil || plan.Renames[1].SetModTime != nil {
+		t.Fatalf("renames = %v, want the mod time of the photo only", plan.Renames)
+	}
+
+	result, err := Apply(plan, ApplyOptions{StateDir: stateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	captured := time.Date(2023, 5, 12, 10, 15, 30, 0, time.Local)
+	if info, _ := os.Stat(filepath.Join(dir, "P_IMG_20230512_101530.jpg")); !info.ModTime().Equal(captured) {
+		t.Errorf("FAIL => Expected: '%v' - Actual: '%v'", captured, info.ModTime())
+	}
+
+	results, err := Undo(result.Journal, UndoOptions{StateDir: stateDir})
+	if err != nil || results[0].Status != UndoRestored || results[1].Status != UndoRestored {
+		t.Fatalf("undo = %v, %v", results, err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "IMG_20230512_101530.jpg")); !info.ModTime().Equal(mtime) {
+		t.Errorf("FAIL => Expected: '%v' - Actual: '%v'", mtime, info.ModTime())
+	}
+}
+
+func TestBuildEmptyName(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "notes.txt", "a.jpg", "IMG_20240512.txt")
+
+	testCases := []struct {
+		template string
+		expected map[string]string
+	}{
+		// A text file has no resolution, a.jpg is empty
+		{template: "{res}", expected: map[string]string{}},
+		// Only IMG_20240512.txt has a date in its name
+		{template: "{date:YYYY}", expected: map[string]string{"IMG_20240512.txt": "2024.txt"}},
+		{template: "{date:YYYY}/{res}", expected: map[string]string{"IMG_20240512.txt": filepath.Join("2024", "IMG_20240512.txt")}},
+	}
+
+	for _, tc := range testCases {
+		opts := DefaultOptions()
+		opts.Template, opts.DateSource = tc.template, "filename"
+
+		plan, err := Build(context.Background(), dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := map[string]string{}
+		for _, r := range plan.Renames {
+			oldName, _ := filepath.Rel(dir, r.OldPath)
+			newName, _ := filepath.Rel(dir, r.NewPath)
+			actual[oldName] = newName
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(tc.expected) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.template, tc.expected, actual)
+		}
+	}
+}
+
+func TestGetItemInTree(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{".git", "sub/deep/deeper"} {
+		os.MkdirAll(filepath.Join(dir, sub), 0755)
+	}
+	writeFiles(t, dir, "a.txt", ".hidden.txt", ".git/x.txt", "sub/b.txt", "sub/deep/c.txt", "sub/deep/deeper/d.txt")
+
+	all := []string{".git", ".hidden.txt", "a.txt", "sub", "sub/b.txt", "sub/deep", "sub/deep/c.txt", "sub/deep/deeper", "sub/deep/deeper/d.txt"}
+	testCases := []struct {
+		maxDepth int
+		hidden   bool
+		expected []string
+	}{
+		// Hidden directories are listed but not walked, hidden files are filtered later
+		{maxDepth: 0, expected: all},
+		{maxDepth: 0, hidden: true, expected: append([]string{".git/x.txt"}, all...)},
+		// The directories at the max depth are listed, not walked
+		{maxDepth: 1, expected: []string{".git", ".hidden.txt", "a.txt", "sub"}},
+		{maxDepth: 2, expected: []string{".git", ".hidden.txt", "a.txt", "sub", "sub/b.txt", "sub/deep"}},
+		{maxDepth: 3, hidden: true, expected: []string{".git", ".git/x.txt", ".hidden.txt", "a.txt", "sub", "sub/b.txt", "sub/deep", "sub/deep/c.txt", "sub/deep/deeper"}},
+	}
+
+	for _, tc := range testCases {
+		items, errs := getItemInTree(dir, tc.maxDepth, tc.hidden)
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+
+		actual := []string{}
+		for _, item := range items {
+			rel, _ := filepath.Rel(dir, filepath.Join(item.dir, item.entry.Name()))
+			actual = append(actual, filepath.ToSlash(rel))
+		}
+		sort.Strings(actual)
+		sort.Strings(tc.expected)
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("FAIL => Input: depth %d, hidden %v, Expected: '%v' - Actual: '%v'", tc.maxDepth, tc.hidden, tc.expected, actual)
+		}
+	}
+}
+
+func TestBuildRecursive(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{".git", "sub/deep"} {
+		os.MkdirAll(filepath.Join(dir, sub), 0755)
+	}
+	writeFiles(t, dir, "a.txt", "b.txt", ".git/x.txt", "sub/a.txt", "sub/deep/a.txt")
+
+	opts := DefaultOptions()
+	opts.Override, opts.Recursive, opts.MaxDepth = "x", true, 2
+
+	plan, err := Build(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same name in different directories is no duplicate, only b.txt gets a unique suffix.
+	// .git is hidden and sub/deep is below the max depth
+	actual := map[string]string{}
+	for _, r := range plan.Renames {
+		oldName, _ := filepath.Rel(dir, r.OldPath)
+		newName, _ := filepath.Rel(dir, r.NewPath)
+		actual[filepath.ToSlash(oldName)] = filepath.ToSlash(newName)
+	}
+	if len(actual) != 3 || actual["a.txt"] != "x.txt" || actual["sub/a.txt"] != "sub/x.txt" || !strings.HasPrefix(actual["b.txt"], "x_") {
+		t.Errorf("FAIL => Expected: a.txt, b.txt and sub/a.txt renamed, b.txt with a unique suffix - Actual: '%v'", actual)
+	}
+}
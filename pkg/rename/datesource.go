@@ -0,0 +1,203 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDateSources = "btime,mtime"
+
+var dateSources = map[timeSource]bool{
+	timeSourceExif:      true,
+	timeSourceQuickTime: true,
+	timeSourceModify:    true,
+	timeSourceBirth:     true,
+	timeSourceFilename:  true,
+}
+
+// Date & time patterns of the file names, tried in order after the user patterns. Only the
+// year, month and day groups are required
+var builtinFilenameDates = []*regexp.Regexp{
+	// Cameras and phones: IMG_20230512_101530, PXL_20230512_101530123, 20230512_101530
+	regexp.MustCompile(`(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})[_-](?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2})`),
+	// WhatsApp: IMG-20230512-WA0001, VID-20230512-WA0001
+	regexp.MustCompile(`(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})-WA\d+`),
+	// macOS screenshots and recordings: Screenshot 2023-05-12 at 10.15.30, ... at 10.15.30 AM
+	regexp.MustCompile(`(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2}) at (?P<hour>\d{1,2})\.(?P<minute>\d{2})\.(?P<second>\d{2})(?:[\s\x{202F}]*(?P<ampm>[AaPp][Mm]))?`),
+	// Any date with an optional time: 2023-05-12, 2023.05.12 10-15-30, Screenshot_20230512-101530
+	regexp.MustCompile(`(?P<year>\d{4})[-_.]?(?P<month>\d{2})[-_.]?(?P<day>\d{2})(?:[-_ T]?(?P<hour>\d{2})[-_.:]?(?P<minute>\d{2})[-_.:]?(?P<second>\d{2}))?`),
+}
+
+var filenameDateGroups = map[string]bool{
+	"year": true, "month": true, "day": true, "hour": true, "minute": true, "second": true, "ampm": true,
+}
+
+// dateReader finds the date of a file with the sources of --date-source, in order
+type dateReader struct {
+	sources []timeSource
+	// User patterns first, then the built-in ones
+	filenamePatterns []*regexp.Regexp
+}
+
+// parseDateSources parses the comma-separated fallback order, e.g. "exif,quicktime,mtime"
+func parseDateSources(value string) ([]timeSource, error) {
+	sources := []timeSource{}
+
+	for _, s := range strings.Split(value, ",") {
+		source := timeSource(strings.ToLower(strings.TrimSpace(s)))
+		if source == "" {
+			continue
+		}
+		if !dateSources[source] {
+			return nil, fmt.Errorf("invalid date source: %s, expected: exif, quicktime, mtime, btime or filename", source)
+		}
+		sources = append(sources, source)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("empty date source, expected: exif, quicktime, mtime, btime or filename")
+	}
+	return sources, nil
+}
+
+// compileFilenamePattern compiles a user pattern, it must capture the year, month and day in
+// named groups
+func compileFilenamePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename date pattern %s: %w", pattern, err)
+	}
+
+	groups := map[string]bool{}
+	for _, name := range re.SubexpNames()[1:] {
+		if name == "" {
+			continue
+		}
+		if !filenameDateGroups[name] {
+			return nil, fmt.Errorf("invalid group %s in filename date pattern %s, expected: year, month, day, hour, minute, second or ampm", name, pattern)
+		}
+		groups[name] = true
+	}
+
+	if !groups["year"] || !groups["month"] || !groups["day"] {
+		return nil, fmt.Errorf("filename date pattern %s must have the year, month and day groups, e.g. (?P<year>\\d{4})", pattern)
+	}
+	return re, nil
+}
+
+func newDateReader(sources string, patterns []string) (*dateReader, error) {
+	r := &dateReader{filenamePatterns: []*regexp.Regexp{}}
+	var err error
+
+	if r.sources, err = parseDateSources(sources); err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		re, err := compileFilenamePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.filenamePatterns = append(r.filenamePatterns, re)
+	}
+	r.filenamePatterns = append(r.filenamePatterns, builtinFilenameDates...)
+
+	return r, nil
+}
+
+// matchFilenameDate returns the date captured by the pattern, in local time. Dates that don't
+// exist (e.g. 2023-13-45) don't match
+func matchFilenameDate(re *regexp.Regexp, name string) (time.Time, bool) {
+	matches := re.FindStringSubmatch(name)
+	if matches == nil {
+		return time.Time{}, false
+	}
+
+	values := map[string]int{}
+	ampm := ""
+	for i, group := range re.SubexpNames() {
+		if group == "ampm" {
+			ampm = strings.ToLower(matches[i])
+		} else if group != "" && matches[i] != "" {
+			n, err := strconv.Atoi(matches[i])
+			if err != nil {
+				return time.Time{}, false
+			}
+			values[group] = n
+		}
+	}
+
+	year, month, day, hour := values["year"], values["month"], values["day"], values["hour"]
+	if year < 100 {
+		year += 2000
+	}
+	if ampm != "" && (hour < 1 || hour > 12) {
+		return time.Time{}, false
+	}
+	if ampm == "pm" && hour < 12 {
+		hour += 12
+	} else if ampm == "am" && hour == 12 {
+		hour = 0
+	}
+
+	if month < 1 || month > 12 || hour > 23 || values["minute"] > 59 || values["second"] > 59 {
+		return time.Time{}, false
+	}
+	date := time.Date(year, time.Month(month), day, hour, values["minute"], values["second"], 0, time.Local)
+	if date.Day() != day {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// filenameDate returns the date of the first pattern matching the name without its extension
+func (r *dateReader) filenameDate(name string) (time.Time, bool) {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	for _, re := range r.filenamePatterns {
+		if date, ok := matchFilenameDate(re, name); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fileDate tries the sources in order and returns the first date found
+func (r *dateReader) fileDate(filePath string, info os.FileInfo) (time.Time, timeSource, bool) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	for _, source := range r.sources {
+		var date time.Time
+		ok := false
+
+		switch source {
+		case timeSourceExif:
+			if goSupportedPhotos[ext] || headerPhotos[ext] {
+				date, ok = getExifDate(filePath, ext)
+			}
+		case timeSourceQuickTime:
+			if isQuickTimeVideo(ext) {
+				date, ok = getQuickTimeDate(filePath)
+			}
+		case timeSourceBirth:
+			var createdFrom timeSource
+			date, createdFrom = getFileCreatedTime(filePath, info)
+			ok = createdFrom == timeSourceBirth
+		case timeSourceModify:
+			date, ok = info.ModTime(), true
+		case timeSourceFilename:
+			date, ok = r.filenameDate(info.Name())
+		}
+
+		if ok {
+			return date, source, true
+		}
+	}
+
+	return time.Time{}, "", false
+}
@@ -0,0 +1,56 @@
+package rename
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilenameDate(t *testing.T) {
+	dates, err := newDateReader("filename", []string{`^DSC_(?P<day>\d{2})(?P<month>\d{2})(?P<year>\d{2})`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(year, month, day, hour, minute, second int) time.Time {
+		return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
+	}
+
+	testCases := []struct {
+		name     string
+		expected time.Time
+		ok       bool
+	}{
+		{name: "IMG_20230512_101530.jpg", expected: at(2023, 5, 12, 10, 15, 30), ok: true},
+		{name: "PXL_20230512_101530123.jpg", expected: at(2023, 5, 12, 10, 15, 30), ok: true},
+		{name: "VID-20230512-WA0001.mp4", expected: at(2023, 5, 12, 0, 0, 0), ok: true},
+		{name: "Screenshot 2023-05-12 at 10.15.30.png", expected: at(2023, 5, 12, 10, 15, 30), ok: true},
+		{name: "Screenshot 2023-05-12 at 1.15.30 PM.png", expected: at(2023, 5, 12, 13, 15, 30), ok: true},
+		{name: "Screen Recording 2023-05-12 at 12.15.30 AM.mov", expected: at(2023, 5, 12, 0, 15, 30), ok: true},
+		{name: "Screenshot_2023-05-12-10-15-30.png", expected: at(2023, 5, 12, 10, 15, 30), ok: true},
+		{name: "2023.05.12 trip.jpg", expected: at(2023, 5, 12, 0, 0, 0), ok: true},
+		{name: "DSC_120523.jpg", expected: at(2023, 5, 12, 0, 0, 0), ok: true},
+		{name: "IMG_20231345_101530.jpg", ok: false},
+		{name: "IMG_0001.jpg", ok: false},
+	}
+
+	for _, tc := range testCases {
+		date, ok := dates.filenameDate(tc.name)
+		if ok != tc.ok || (ok && !date.Equal(tc.expected)) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v', '%v' - Actual: '%v', '%v'", tc.name, tc.expected, tc.ok, date, ok)
+		}
+	}
+}
+
+func TestInvalidFilenameDate(t *testing.T) {
+	patterns := []string{
+		`(?P<year>\d{4`,
+		`(?P<year>\d{4})(?P<month>\d{2})`,
+		`(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})(?P<hours>\d{2})`,
+	}
+
+	for _, pattern := range patterns {
+		if _, err := newDateReader("filename", []string{pattern}); err == nil {
+			t.Errorf("FAIL => Input: %v, Expected: error - Actual: nil", pattern)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package rename
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	dedupeReport = "report"
+	dedupeSkip   = "skip"
+	dedupeMove   = "move"
+)
+
+var dedupeModes = map[string]bool{
+	dedupeReport: true,
+	dedupeSkip:   true,
+	dedupeMove:   true,
+}
+
+// hashFile returns the hex SHA-256 of the file content
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hash returns the cached content hash of the file, or computes it. Empty when the file can't
+// be read, e.g. a directory. A nil cache always computes it
+func (c *mediaCache) hash(filePath string) string {
+	if c == nil {
+		hash, _ := hashFile(filePath)
+		return hash
+	}
+
+	c.mu.Lock()
+	hash, ok := c.hashes[filePath]
+	c.mu.Unlock()
+	if ok {
+		return hash
+	}
+
+	hash, _ = hashFile(filePath)
+
+	c.mu.Lock()
+	c.hashes[filePath] = hash
+	c.mu.Unlock()
+
+	return hash
+}
+
+// findDuplicates groups the indexes of byte-identical files, in walk order so the first one of
+// each group is the original. Only the files sharing their size with another one are hashed,
+// empty files are ignored
+func findDuplicates(ctx context.Context, items []dirItem, jobs int, progress func(done, total int), media *mediaCache) (groups [][]int, cancelled bool) {
+	bySize := map[int64][]int{}
+	for i, item := range items {
+		if !item.entry.Type().IsRegular() {
+			continue
+		}
+		info, err := item.entry.Info()
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], i)
+	}
+
+	candidates := []int{}
+	for _, indexes := range bySize {
+		if len(indexes) > 1 {
+			candidates = append(candidates, indexes...)
+		}
+	}
+	sort.Ints(candidates)
+
+	hashes := make([]string, len(candidates))
+	done := runPool(ctx, len(candidates), jobs, progress, func(k int) {
+		item := items[candidates[k]]
+		hashes[k] = media.hash(filepath.Join(item.dir, item.entry.Name()))
+	})
+	for _, ok := range done {
+		if !ok {
+			return nil, true
+		}
+	}
+
+	byHash := map[string]int{}
+	for k, i := range candidates {
+		if hashes[k] == "" {
+			continue
+		}
+		if g, ok := byHash[hashes[k]]; ok {
+			groups[g] = append(groups[g], i)
+			continue
+		}
+		byHash[hashes[k]] = len(groups)
+		groups = append(groups, []int{i})
+	}
+
+	// Files with a unique content are not duplicates
+	duplicates := groups[:0]
+	for _, group := range groups {
+		if len(group) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+
+	return duplicates, false
+}
+
+// duplicatePaths returns the paths of each group, the original first
+func duplicatePaths(items []dirItem, groups [][]int) [][]string {
+	paths := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		groupPaths := make([]string, 0, len(group))
+		for _, i := range group {
+			groupPaths = append(groupPaths, filepath.Join(items[i].dir, items[i].entry.Name()))
+		}
+		paths = append(paths, groupPaths)
+	}
+	return paths
+}
+
+// setDuplicatesAside returns the items without the duplicates. With the move mode, the moves
+// of the duplicates to DedupeDir are added to renamed, so they go through the conflict
+// checks, the plan and the undo journal like any other rename
+func setDuplicatesAside(path string, items []dirItem, groups [][]int, opts *config, renamed map[string]string) []dirItem {
+	isDuplicate := map[int]bool{}
+	for _, group := range groups {
+		for _, i := range group[1:] {
+			isDuplicate[i] = true
+		}
+	}
+
+	dedupeDir := opts.DedupeDir
+	if !filepath.IsAbs(dedupeDir) {
+		dedupeDir = filepath.Join(path, dedupeDir)
+	}
+
+	kept := make([]dirItem, 0, len(items)-len(isDuplicate))
+	for i, item := range items {
+		if !isDuplicate[i] {
+			kept = append(kept, item)
+			continue
+		}
+
+		if opts.Dedupe != dedupeMove {
+			continue
+		}
+
+		name := item.entry.Name()
+		newPath := filepath.Join(dedupeDir, name)
+		if _, exists := renamed[newPath]; exists {
+			newPath = filepath.Join(dedupeDir, withUniqueSuffix(name, opts.Separator))
+		}
+		renamed[newPath] = filepath.Join(item.dir, name)
+	}
+
+	return kept
+}
@@ -0,0 +1,89 @@
+package rename
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	files := map[string]string{
+		"a.txt":     "same",
+		"b.txt":     "same",
+		"c.txt":     "other",
+		"d.txt":     "size",
+		"sub/a.txt": "same",
+		"empty1":    "",
+		"empty2":    "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		mode     string
+		expected map[string]string
+	}{
+		{
+			mode: dedupeReport,
+			expected: map[string]string{
+				"P_a.txt": "a.txt", "P_b.txt": "b.txt", "P_c.txt": "c.txt", "P_d.txt": "d.txt",
+				"P_empty1": "empty1", "P_empty2": "empty2", "sub/P_a.txt": "sub/a.txt",
+			},
+		},
+		{
+			mode: dedupeSkip,
+			expected: map[string]string{
+				"P_a.txt": "a.txt", "P_c.txt": "c.txt", "P_d.txt": "d.txt",
+				"P_empty1": "empty1", "P_empty2": "empty2",
+			},
+		},
+		{
+			mode: dedupeMove,
+			expected: map[string]string{
+				"P_a.txt": "a.txt", "P_c.txt": "c.txt", "P_d.txt": "d.txt",
+				"P_empty1": "empty1", "P_empty2": "empty2",
+				"duplicates/b.txt": "b.txt", "duplicates/a_1.txt": "sub/a.txt",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		opts := DefaultOptions()
+		opts.Prefix, opts.Recursive, opts.Dedupe, opts.Jobs = "P", true, tc.mode, 2
+
+		plan, err := Build(context.Background(), dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Report.Duplicates) != 1 || len(plan.Report.Duplicates[0]) != 3 {
+			t.Errorf("FAIL => Mode: %v, Expected: one group of 3 duplicates - Actual: %v", tc.mode, plan.Report.Duplicates)
+		}
+
+		actual := map[string]string{}
+		for _, r := range plan.Renames {
+			newRel, _ := filepath.Rel(dir, r.NewPath)
+			oldRel, _ := filepath.Rel(dir, r.OldPath)
+			actual[filepath.ToSlash(newRel)] = filepath.ToSlash(oldRel)
+		}
+
+		// The second duplicate named a.txt gets a unique suffix
+		for newRel, oldRel := range actual {
+			if oldRel == "sub/a.txt" && filepath.Dir(newRel) == "duplicates" {
+				delete(actual, newRel)
+				actual["duplicates/a_1.txt"] = oldRel
+			}
+		}
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("FAIL => Mode: %v, Expected: %v - Actual: %v", tc.mode, tc.expected, actual)
+		}
+	}
+}
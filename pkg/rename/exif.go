@@ -0,0 +1,353 @@
+package rename
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exifLayout = "2006:01:02 15:04:05"
+
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+	tagOffsetTime        = 0x9010
+	tagOffsetTimeOrig    = 0x9011
+
+	typeASCII = 2
+	typeLong  = 4
+
+	// EXIF data is small, anything bigger than this is not worth reading
+	maxExifSize = 1 << 20
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTiffReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, errors.New("invalid tiff header")
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("invalid tiff byte order")
+	}
+
+	return &tiffReader{data: data, order: order}, nil
+}
+
+// readIFD returns the raw 12-byte entries of the IFD at offset, keyed by tag
+func (t *tiffReader) readIFD(offset uint32) map[uint16][]byte {
+	entries := map[uint16][]byte{}
+	if int(offset)+2 > len(t.data) {
+		return entries
+	}
+
+	count := int(t.order.Uint16(t.data[offset:]))
+	start := int(offset) + 2
+	for i := 0; i < count && start+12*(i+1) <= len(t.data); i++ {
+		entry := t.data[start+12*i : start+12*(i+1)]
+		entries[t.order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+func (t *tiffReader) long(entry []byte) (uint32, bool) {
+	if entry == nil || t.order.Uint16(entry[2:]) != typeLong {
+		return 0, false
+	}
+	return t.order.Uint32(entry[8:]), true
+}
+
+func (t *tiffReader) ascii(entry []byte) (string, bool) {
+	if entry == nil || t.order.Uint16(entry[2:]) != typeASCII {
+		return "", false
+	}
+
+	count := t.order.Uint32(entry[4:])
+	value := entry[8:12]
+	if count > 4 {
+		offset := t.order.Uint32(entry[8:])
+		if uint64(offset)+uint64(count) > uint64(len(t.data)) {
+			return "", false
+		}
+		value = t.data[offset : offset+count]
+	} else {
+		value = value[:count]
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(value), "\x00")), true
+}
+
+// parseExifDate reads DateTimeOriginal (then DateTimeDigitized, then DateTime) from a TIFF
+// structure. The wall clock of the camera is kept, with its UTC offset when it is recorded
+func parseExifDate(data []byte) (time.Time, bool) {
+	t, err := newTiffReader(data)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	ifd0 := t.readIFD(t.order.Uint32(data[4:]))
+	exifIFD := map[uint16][]byte{}
+	if offset, ok := t.long(ifd0[tagExifIFD]); ok {
+		exifIFD = t.readIFD(offset)
+	}
+
+	candidates := []struct {
+		date, offset []byte
+	}{
+		{exifIFD[tagDateTimeOriginal], exifIFD[tagOffsetTimeOrig]},
+		{exifIFD[tagDateTimeDigitized], exifIFD[tagOffsetTime]},
+		{ifd0[tagDateTime], exifIFD[tagOffsetTime]},
+	}
+
+	for _, c := range candidates {
+		value, ok := t.ascii(c.date)
+		if !ok {
+			continue
+		}
+
+		loc := time.Local
+		if offset, ok := t.ascii(c.offset); ok {
+			if zone, err := time.Parse("-07:00", offset); err == nil {
+				loc = zone.Location()
+			}
+		}
+
+		if date, err := time.ParseInLocation(exifLayout, value, loc); err == nil && date.Year() > 1 {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// getJPEGExif returns the TIFF structure stored in the APP1 segment of a JPEG file
+func getJPEGExif(r io.Reader) ([]byte, bool) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header[:2]); err != nil || header[0] != 0xFF || header[1] != 0xD8 {
+		return nil, false
+	}
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil || header[0] != 0xFF {
+			return nil, false
+		}
+
+		marker := header[1]
+		length := int(binary.BigEndian.Uint16(header[2:])) - 2
+
+		// Start of scan or end of image, there is no metadata after this point
+		if marker == 0xDA || marker == 0xD9 || length < 0 {
+			return nil, false
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, false
+		}
+
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):], true
+		}
+	}
+}
+
+// getHEIFExif returns the TIFF structure stored in the "Exif" item of a HEIC/HEIF/AVIF file
+func getHEIFExif(f *os.File, size int64) ([]byte, bool) {
+	iinf, err := findBox(f, size, "meta", "iinf")
+	if err != nil {
+		return nil, false
+	}
+
+	itemID, ok := getHEIFItemID(f, iinf, "Exif")
+	if !ok {
+		return nil, false
+	}
+
+	iloc, err := findBox(f, size, "meta", "iloc")
+	if err != nil {
+		return nil, false
+	}
+
+	offset, length, ok := getHEIFItemLocation(f, iloc, itemID)
+	if !ok || length < 4 {
+		return nil, false
+	}
+
+	data, err := readBoxPayload(f, box{offset: offset, size: length}, maxExifSize)
+	if err != nil {
+		return nil, false
+	}
+
+	// The payload starts with the offset of the TIFF header, usually pointing after "Exif\0\0"
+	tiffOffset := uint64(binary.BigEndian.Uint32(data)) + 4
+	if tiffOffset >= uint64(len(data)) {
+		return nil, false
+	}
+	return data[tiffOffset:], true
+}
+
+// getHEIFItemID finds the id of the first item of the given type in the "iinf" box
+func getHEIFItemID(r io.ReaderAt, iinf box, itemType string) (uint64, bool) {
+	data, err := readBoxPayload(r, iinf, maxExifSize)
+	if err != nil || len(data) < 4 {
+		return 0, false
+	}
+
+	countSize := 2
+	if data[0] > 0 {
+		countSize = 4
+	}
+	if _, _, ok := readUint(data[4:], countSize); !ok {
+		return 0, false
+	}
+
+	infeStart := iinf.offset + 4 + int64(countSize)
+	entries, _ := readBoxes(r, infeStart, iinf.offset+iinf.size)
+
+	for _, entry := range entries {
+		if entry.typ != "infe" {
+			continue
+		}
+
+		infe, err := readBoxPayload(r, entry, 64)
+		if err != nil || len(infe) < 4 || infe[0] < 2 {
+			continue
+		}
+
+		idSize := 2
+		if infe[0] == 3 {
+			idSize = 4
+		}
+
+		id, rest, ok := readUint(infe[4:], idSize)
+		// Skip item_protection_index before the item type
+		if !ok || len(rest) < 6 {
+			continue
+		}
+
+		if string(rest[2:6]) == itemType {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// getHEIFItemLocation returns the file offset and length of the first extent of an item
+func getHEIFItemLocation(r io.ReaderAt, iloc box, itemID uint64) (int64, int64, bool) {
+	data, err := readBoxPayload(r, iloc, maxExifSize)
+	if err != nil || len(data) < 6 {
+		return 0, 0, false
+	}
+
+	version := data[0]
+	offsetSize, lengthSize := int(data[4]>>4), int(data[4]&0x0F)
+	baseOffsetSize, indexSize := int(data[5]>>4), 0
+	if version == 1 || version == 2 {
+		indexSize = int(data[5] & 0x0F)
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+
+	itemCount, rest, ok := readUint(data[6:], idSize)
+	if !ok {
+		return 0, 0, false
+	}
+
+	for i := uint64(0); i < itemCount; i++ {
+		var id, baseOffset, extentCount uint64
+
+		if id, rest, ok = readUint(rest, idSize); !ok {
+			return 0, 0, false
+		}
+
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			if constructionMethod, rest, ok = readUint(rest, 2); !ok {
+				return 0, 0, false
+			}
+			constructionMethod &= 0x0F
+		}
+
+		// data_reference_index
+		if _, rest, ok = readUint(rest, 2); !ok {
+			return 0, 0, false
+		}
+		if baseOffset, rest, ok = readUint(rest, baseOffsetSize); !ok {
+			return 0, 0, false
+		}
+		if extentCount, rest, ok = readUint(rest, 2); !ok {
+			return 0, 0, false
+		}
+
+		for e := uint64(0); e < extentCount; e++ {
+			var extentOffset, extentLength uint64
+
+			if _, rest, ok = readUint(rest, indexSize); !ok {
+				return 0, 0, false
+			}
+			if extentOffset, rest, ok = readUint(rest, offsetSize); !ok {
+				return 0, 0, false
+			}
+			if extentLength, rest, ok = readUint(rest, lengthSize); !ok {
+				return 0, 0, false
+			}
+
+			// Only items stored directly in the file are supported
+			if e == 0 && id == itemID && constructionMethod == 0 {
+				return int64(baseOffset + extentOffset), int64(extentLength), true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// getExifDate reads the capture date of JPEG and HEIC/HEIF/AVIF photos
+func getExifDate(filePath, ext string) (time.Time, bool) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	var tiff []byte
+	var ok bool
+
+	switch {
+	case isGoSupportedPhoto(ext):
+		tiff, ok = getJPEGExif(f)
+	case isISOBMFFPhoto(ext):
+		info, err := f.Stat()
+		if err != nil {
+			return time.Time{}, false
+		}
+		tiff, ok = getHEIFExif(f, info.Size())
+	}
+
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseExifDate(tiff)
+}
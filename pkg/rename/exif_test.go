@@ -0,0 +1,170 @@
+package rename
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTiff creates a little-endian TIFF structure with IFD0 => Exif IFD => DateTimeOriginal
+func buildTiff(date, offset string) []byte {
+	le := binary.LittleEndian
+	buf := &bytes.Buffer{}
+	buf.WriteString("II*\x00")
+	binary.Write(buf, le, uint32(8))
+
+	// IFD0 at 8: 1 entry pointing to the Exif IFD at 26
+	binary.Write(buf, le, uint16(1))
+	binary.Write(buf, le, []uint16{tagExifIFD, typeLong})
+	binary.Write(buf, le, []uint32{1, 26})
+	binary.Write(buf, le, uint32(0))
+
+	// Exif IFD at 26, values are stored after it at 56
+	entryCount := uint16(1)
+	if offset != "" {
+		entryCount = 2
+	}
+	valueOffset := uint32(26 + 2 + 12*int(entryCount) + 4)
+	binary.Write(buf, le, entryCount)
+	binary.Write(buf, le, []uint16{tagDateTimeOriginal, typeASCII})
+	binary.Write(buf, le, []uint32{uint32(len(date) + 1), valueOffset})
+	if offset != "" {
+		binary.Write(buf, le, []uint16{tagOffsetTimeOrig, typeASCII})
+		binary.Write(buf, le, []uint32{uint32(len(offset) + 1), valueOffset + uint32(len(date)+1)})
+	}
+	binary.Write(buf, le, uint32(0))
+
+	buf.WriteString(date + "\x00")
+	if offset != "" {
+		buf.WriteString(offset + "\x00")
+	}
+	return buf.Bytes()
+}
+
+func buildBox(typ string, payloads ...[]byte) []byte {
+	payload := bytes.Join(payloads, nil)
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint32(8+len(payload)))
+	buf.WriteString(typ)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func buildJPEG(tiff []byte) []byte {
+	app1 := append(append([]byte{}, exifHeader...), tiff...)
+	buf := &bytes.Buffer{}
+	buf.Write([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00})
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(buf, binary.BigEndian, uint16(len(app1)+2))
+	buf.Write(app1)
+	buf.Write([]byte{0xFF, 0xD9})
+	return buf.Bytes()
+}
+
+func buildHEIF(tiff []byte) []byte {
+	ftyp := buildBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	infe := buildBox("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif"), []byte{0})
+	iinf := buildBox("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+
+	exifItem := append(append([]byte{0, 0, 0, 6}, exifHeader...), tiff...)
+	ilocSize := 8 + 4 + 2 + 2 + 2 + 2 + 2 + 4 + 4
+	metaSize := 8 + 4 + len(iinf) + ilocSize
+	itemOffset := uint32(len(ftyp) + metaSize + 8)
+
+	iloc := &bytes.Buffer{}
+	iloc.Write([]byte{0, 0, 0, 0, 0x44, 0x00})
+	binary.Write(iloc, binary.BigEndian, []uint16{1, 1, 0, 1})
+	binary.Write(iloc, binary.BigEndian, []uint32{itemOffset, uint32(len(exifItem))})
+
+	meta := buildBox("meta", []byte{0, 0, 0, 0}, iinf, buildBox("iloc", iloc.Bytes()))
+	return bytes.Join([][]byte{ftyp, meta, buildBox("mdat", exifItem)}, nil)
+}
+
+func buildMP4(version byte, seconds uint64) []byte {
+	mvhd := &bytes.Buffer{}
+	mvhd.Write([]byte{version, 0, 0, 0})
+	if version == 1 {
+		binary.Write(mvhd, binary.BigEndian, seconds)
+	} else {
+		binary.Write(mvhd, binary.BigEndian, uint32(seconds))
+	}
+	mvhd.Write(make([]byte, 20))
+
+	ftyp := buildBox("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	return bytes.Join([][]byte{ftyp, buildBox("moov", buildBox("mvhd", mvhd.Bytes()))}, nil)
+}
+
+func TestGetMetadataDate(t *testing.T) {
+	dir := t.TempDir()
+	captured := time.Date(2023, 5, 12, 10, 15, 30, 0, time.Local)
+	capturedInHanoi := time.Date(2023, 5, 12, 10, 15, 30, 0, time.FixedZone("", 7*3600))
+	qtSeconds := uint64(captured.Unix() - quickTimeEpoch.Unix())
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected time.Time
+		ok       bool
+	}{
+		{name: "photo.jpg", data: buildJPEG(buildTiff("2023:05:12 10:15:30", "")), expected: captured, ok: true},
+		{name: "photo_tz.JPG", data: buildJPEG(buildTiff("2023:05:12 10:15:30", "+07:00")), expected: capturedInHanoi, ok: true},
+		{name: "photo.heic", data: buildHEIF(buildTiff("2023:05:12 10:15:30", "")), expected: captured, ok: true},
+		{name: "video.mp4", data: buildMP4(0, qtSeconds), expected: captured, ok: true},
+		{name: "video.mov", data: buildMP4(1, qtSeconds), expected: captured, ok: true},
+		{name: "empty_date.mov", data: buildMP4(0, 0), ok: false},
+		{name: "no_exif.jpg", data: []byte{0xFF, 0xD8, 0xFF, 0xD9}, ok: false},
+		{name: "broken.heic", data: []byte("not a heic file"), ok: false},
+	}
+
+	for _, tc := range testCases {
+		filePath := filepath.Join(dir, tc.name)
+		if err := os.WriteFile(filePath, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		info, _ := os.Stat(filePath)
+		date, source, ok := (&dateReader{sources: []timeSource{timeSourceExif, timeSourceQuickTime}}).fileDate(filePath, info)
+
+		if ok != tc.ok || (ok && !date.Equal(tc.expected)) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v', '%v' - Actual: '%v', '%v' (%v)", tc.name, tc.expected, tc.ok, date, ok, source)
+		}
+	}
+}
+
+func TestGetFileDateFallback(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.Local)
+
+	testCases := []struct {
+		name     string
+		sources  string
+		expected timeSource
+	}{
+		{name: "no_exif.jpg", sources: "exif,mtime", expected: timeSourceModify},
+		{name: "IMG_20230512_101530.jpg", sources: "exif,filename,mtime", expected: timeSourceFilename},
+		{name: "video.mkv", sources: "quicktime,exif", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		filePath := filepath.Join(dir, tc.name)
+		os.WriteFile(filePath, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644)
+		os.Chtimes(filePath, mtime, mtime)
+		info, _ := os.Stat(filePath)
+
+		dates, err := newDateReader(tc.sources, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, source, _ := dates.fileDate(filePath, info); source != tc.expected {
+			t.Errorf("FAIL => Input: %v %v, Expected: '%v' - Actual: '%v'", tc.name, tc.sources, tc.expected, source)
+		}
+	}
+
+	if _, err := parseDateSources("exif,gps"); err == nil {
+		t.Errorf("FAIL => Input: exif,gps, Expected: error - Actual: nil")
+	}
+}
@@ -0,0 +1,14 @@
+package rename
+
+// timeSource tells where the date of a file comes from, a filesystem timestamp or its metadata
+type timeSource string
+
+const (
+	timeSourceBirth     timeSource = "btime"
+	timeSourceChange    timeSource = "ctime"
+	timeSourceAccess    timeSource = "atime"
+	timeSourceModify    timeSource = "mtime"
+	timeSourceExif      timeSource = "exif"
+	timeSourceQuickTime timeSource = "quicktime"
+	timeSourceFilename  timeSource = "filename"
+)
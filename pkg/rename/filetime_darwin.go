@@ -0,0 +1,48 @@
+//go:build darwin
+
+package rename
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func getFileCreatedTime(_ string, info os.FileInfo) (time.Time, timeSource) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.ModTime(), timeSourceModify
+	}
+
+	switch {
+	case stat.Birthtimespec.Sec > 0:
+		return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec), timeSourceBirth
+	case stat.Ctimespec.Sec > 0:
+		return time.Unix(stat.Ctimespec.Sec, stat.Ctimespec.Nsec), timeSourceChange
+	case stat.Atimespec.Sec > 0:
+		return time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec), timeSourceAccess
+	}
+
+	return info.ModTime(), timeSourceModify
+}
+
+func getFileAccessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Atimespec.Sec, stat.Atimespec.Nsec)
+	}
+	return info.ModTime()
+}
+
+// newBirthTime returns the birth time a file gets when its times are set to date, nil when it
+// doesn't change: utimes moves the birth time back when the mod time is set before it
+func newBirthTime(birth *time.Time, date time.Time) *time.Time {
+	if birth != nil && date.Before(*birth) {
+		return &date
+	}
+	return nil
+}
+
+// setFileBirthTime has nothing to do, os.Chtimes already moved the birth time back
+func setFileBirthTime(_ string, _ time.Time) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package rename
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// getFileCreatedTime reads the birth time with statx (kernel 4.11+), falls back to mtime
+// when the kernel or the filesystem doesn't report it
+func getFileCreatedTime(filePath string, info os.FileInfo) (time.Time, timeSource) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, filePath, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stx)
+
+	if err == nil && stx.Mask&unix.STATX_BTIME != 0 && stx.Btime.Sec > 0 {
+		return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), timeSourceBirth
+	}
+
+	return info.ModTime(), timeSourceModify
+}
+
+func getFileAccessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+	}
+	return info.ModTime()
+}
+
+// newBirthTime returns the birth time a file gets when its times are set to date, nil when it
+// doesn't change: Linux has no call to set it
+func newBirthTime(_ *time.Time, _ time.Time) *time.Time {
+	return nil
+}
+
+func setFileBirthTime(_ string, _ time.Time) error {
+	return nil
+}
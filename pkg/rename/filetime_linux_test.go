@@ -0,0 +1,67 @@
+//go:build linux
+
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestGetFileCreatedTime(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(filePath, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Move mtime far away from the birth time so the two sources can't be confused
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stx unix.Statx_t
+	hasBtime := unix.Statx(unix.AT_FDCWD, filePath, 0, unix.STATX_BTIME, &stx) == nil && stx.Mask&unix.STATX_BTIME != 0
+
+	createdTime, source := getFileCreatedTime(filePath, info)
+
+	if hasBtime {
+		if source != timeSourceBirth {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", filePath, timeSourceBirth, source)
+		}
+		if createdTime.Equal(mtime) || time.Since(createdTime) > time.Minute {
+			t.Errorf("FAIL => Input: %v, Expected: birth time close to now - Actual: '%v'", filePath, createdTime)
+		}
+		return
+	}
+
+	if source != timeSourceModify || !createdTime.Equal(mtime) {
+		t.Errorf("FAIL => Input: %v, Expected: '%v', '%v' - Actual: '%v', '%v'", filePath, timeSourceModify, mtime, source, createdTime)
+	}
+}
+
+func TestGetFileCreatedTimeFallback(t *testing.T) {
+	// statx fails for a missing file, the mtime from the given info must be used
+	filePath := filepath.Join(t.TempDir(), "missing.jpg")
+	info := fakeFileInfo{modTime: time.Date(2020, 5, 12, 10, 15, 30, 0, time.UTC)}
+
+	createdTime, source := getFileCreatedTime(filePath, info)
+	if source != timeSourceModify || !createdTime.Equal(info.modTime) {
+		t.Errorf("FAIL => Input: %v, Expected: '%v', '%v' - Actual: '%v', '%v'", filePath, timeSourceModify, info.modTime, source, createdTime)
+	}
+}
+
+type fakeFileInfo struct {
+	os.FileInfo
+	modTime time.Time
+}
+
+func (fi fakeFileInfo) ModTime() time.Time { return fi.modTime }
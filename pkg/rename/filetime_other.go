@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !windows
+
+package rename
+
+import (
+	"os"
+	"time"
+)
+
+func getFileCreatedTime(_ string, info os.FileInfo) (time.Time, timeSource) {
+	return info.ModTime(), timeSourceModify
+}
+
+func getFileAccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+func newBirthTime(_ *time.Time, _ time.Time) *time.Time {
+	return nil
+}
+
+func setFileBirthTime(_ string, _ time.Time) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package rename
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func getFileCreatedTime(_ string, info os.FileInfo) (time.Time, timeSource) {
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, attrs.CreationTime.Nanoseconds()), timeSourceBirth
+	}
+	return info.ModTime(), timeSourceModify
+}
+
+func getFileAccessTime(info os.FileInfo) time.Time {
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, attrs.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
+
+// newBirthTime returns the birth time a file gets when its times are set to date, NTFS keeps
+// a creation time that SetFileTime can change
+func newBirthTime(birth *time.Time, date time.Time) *time.Time {
+	if birth != nil && birth.Equal(date) {
+		return nil
+	}
+	return &date
+}
+
+func setFileBirthTime(filePath string, date time.Time) error {
+	path, err := windows.UTF16PtrFromString(filePath)
+	if err != nil {
+		return err
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is needed to open directories
+	handle, err := windows.CreateFile(path, windows.FILE_WRITE_ATTRIBUTES, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(handle)
+
+	created := windows.NsecToFiletime(date.UnixNano())
+	return windows.SetFileTime(handle, &created, nil, nil)
+}
@@ -0,0 +1,230 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kindPhoto = "photo"
+	kindVideo = "video"
+)
+
+var mediaKinds = map[string]bool{
+	kindPhoto: true,
+	kindVideo: true,
+}
+
+// Units of --min-size and --max-size, in powers of 1024
+var sizeUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1 << 10,
+	"kb": 1 << 10,
+	"m":  1 << 20,
+	"mb": 1 << 20,
+	"g":  1 << 30,
+	"gb": 1 << 30,
+	"t":  1 << 40,
+	"tb": 1 << 40,
+}
+
+var sizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+// Layouts accepted by --after and --before, date only layouts are in local time
+var filterDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// itemFilter holds the filters of the command line, parsed and validated before any file is read
+type itemFilter struct {
+	include, exclude       *regexp.Regexp
+	globs, excludeGlobs    []string
+	kinds                  map[string]bool
+	minSize, maxSize       int64
+	after, before          time.Time
+	hasMinSize, hasMaxSize bool
+	dates                  *dateReader
+}
+
+// parseSize parses e.g. 500, 10k, 1.5MB or 2G
+func parseSize(s string) (int64, error) {
+	match := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %s, expected e.g. 500, 10K, 1.5MB or 2G", s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %s, expected B, K, M, G or T", match[2])
+	}
+
+	value, _ := strconv.ParseFloat(match[1], 64)
+	return int64(value * float64(unit)), nil
+}
+
+func parseFilterDate(s string) (time.Time, error) {
+	for _, layout := range filterDateLayouts {
+		if date, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, expected YYYY-MM-DD or YYYY-MM-DDTHH:mm:ss", s)
+}
+
+func compileFilterRegex(flag, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s regex: %v", flag, err)
+	}
+	return re, nil
+}
+
+func validateGlobs(flag string, globs []string) error {
+	for _, glob := range globs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid --%s pattern %s: %v", flag, glob, err)
+		}
+	}
+	return nil
+}
+
+// getItemFilter validates every pattern and value of the filter flags up front
+func getItemFilter(opts *config) (*itemFilter, error) {
+	f := &itemFilter{globs: opts.Globs, excludeGlobs: opts.ExcludeGlobs, dates: opts.dates}
+	var err error
+
+	if f.include, err = compileFilterRegex("include", opts.Include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compileFilterRegex("exclude", opts.Exclude); err != nil {
+		return nil, err
+	}
+
+	if err := validateGlobs("glob", opts.Globs); err != nil {
+		return nil, err
+	}
+	if err := validateGlobs("exclude-glob", opts.ExcludeGlobs); err != nil {
+		return nil, err
+	}
+
+	if opts.Kind != "" {
+		f.kinds = map[string]bool{}
+		for _, kind := range strings.Split(opts.Kind, ",") {
+			kind = strings.TrimSpace(kind)
+			if !mediaKinds[kind] {
+				return nil, fmt.Errorf("invalid kind %s, expected: photo or video", kind)
+			}
+			f.kinds[kind] = true
+		}
+	}
+
+	if opts.MinSize != "" {
+		if f.minSize, err = parseSize(opts.MinSize); err != nil {
+			return nil, err
+		}
+		f.hasMinSize = true
+	}
+	if opts.MaxSize != "" {
+		if f.maxSize, err = parseSize(opts.MaxSize); err != nil {
+			return nil, err
+		}
+		f.hasMaxSize = true
+	}
+	if f.hasMinSize && f.hasMaxSize && f.minSize > f.maxSize {
+		return nil, fmt.Errorf("--min-size %s must not be more than --max-size %s", opts.MinSize, opts.MaxSize)
+	}
+
+	if opts.After != "" {
+		if f.after, err = parseFilterDate(opts.After); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Before != "" {
+		if f.before, err = parseFilterDate(opts.Before); err != nil {
+			return nil, err
+		}
+	}
+	if !f.after.IsZero() && !f.before.IsZero() && !f.after.Before(f.before) {
+		return nil, fmt.Errorf("--after %s must be before --before %s", opts.After, opts.Before)
+	}
+
+	return f, nil
+}
+
+func matchAnyGlob(globs []string, name string) bool {
+	for _, glob := range globs {
+		// Patterns are validated up front
+		if matched, _ := filepath.Match(glob, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func getMediaKind(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case goSupportedPhotos[ext] || headerPhotos[ext]:
+		return kindPhoto
+	case videoExtensions[ext]:
+		return kindVideo
+	}
+	return ""
+}
+
+// match tells whether the item passes every filter, a nil filter lets everything through
+func (f *itemFilter) match(item dirItem) bool {
+	if f == nil {
+		return true
+	}
+
+	name := item.entry.Name()
+
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+
+	if matchAnyGlob(f.excludeGlobs, name) {
+		return false
+	}
+	if len(f.globs) > 0 && !matchAnyGlob(f.globs, name) {
+		return false
+	}
+
+	if f.kinds != nil && !f.kinds[getMediaKind(name)] {
+		return false
+	}
+
+	if !f.hasMinSize && !f.hasMaxSize && f.after.IsZero() && f.before.IsZero() {
+		return true
+	}
+
+	info, err := item.entry.Info()
+	if err != nil {
+		return false
+	}
+
+	if (f.hasMinSize && info.Size() < f.minSize) || (f.hasMaxSize && info.Size() > f.maxSize) {
+		return false
+	}
+
+	if !f.after.IsZero() || !f.before.IsZero() {
+		// Files without a date can't be in the range
+		date, _, found := f.dates.fileDate(filepath.Join(item.dir, name), info)
+		if !found || (!f.after.IsZero() && date.Before(f.after)) || (!f.before.IsZero() && !date.Before(f.before)) {
+			return false
+		}
+	}
+
+	return true
+}
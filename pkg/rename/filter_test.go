@@ -0,0 +1,95 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	testCases := map[string]int64{
+		"500":   500,
+		"10k":   10 << 10,
+		"1.5MB": 3 << 19,
+		"2 G":   2 << 30,
+	}
+
+	for input, expected := range testCases {
+		if size, err := parseSize(input); err != nil || size != expected {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v', %v", input, expected, size, err)
+		}
+	}
+
+	for _, input := range []string{"", "-1", "10X", "ten"} {
+		if _, err := parseSize(input); err == nil {
+			t.Errorf("FAIL => Input: %v, Expected: error - Actual: nil", input)
+		}
+	}
+}
+
+func TestGetItemFilterError(t *testing.T) {
+	testCases := []Options{
+		{Include: "(unclosed"},
+		{Exclude: "[a-"},
+		{Globs: []string{"[a-"}},
+		{ExcludeGlobs: []string{"*.jpg", "[]"}},
+		{Kind: "photo,audio"},
+		{MinSize: "big"},
+		{MinSize: "10M", MaxSize: "1M"},
+		{After: "yesterday"},
+		{After: "2024-02-01", Before: "2024-01-01"},
+	}
+
+	for _, opts := range testCases {
+		if _, err := getItemFilter(&config{Options: opts}); err == nil {
+			t.Errorf("FAIL => Input: %+v, Expected: error - Actual: nil", opts)
+		}
+	}
+}
+
+func TestItemFilter(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{"a.jpg": 10, "b_edited.jpg": 10, "c.mp4": 2000, "d.HEIC": 500, "e.txt": 10}
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Date(2020, 5, 1, 12, 0, 0, 0, time.Local)
+	os.Chtimes(filepath.Join(dir, "a.jpg"), old, old)
+
+	testCases := []struct {
+		opts     Options
+		expected []string
+	}{
+		{opts: Options{}, expected: []string{"a.jpg", "b_edited.jpg", "c.mp4", "d.HEIC", "e.txt"}},
+		{opts: Options{Globs: []string{"*.jpg", "*.txt"}, ExcludeGlobs: []string{"*_edited.*"}}, expected: []string{"a.jpg", "e.txt"}},
+		{opts: Options{Kind: "photo"}, expected: []string{"a.jpg", "b_edited.jpg", "d.HEIC"}},
+		{opts: Options{Kind: "photo,video", MinSize: "100", MaxSize: "1K"}, expected: []string{"d.HEIC"}},
+		{opts: Options{Include: `\.jpg$`, After: "2021-01-01"}, expected: []string{"b_edited.jpg"}},
+		{opts: Options{Exclude: `^[bc]`, Before: "2021-01-01"}, expected: []string{"a.jpg"}},
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, tc := range testCases {
+		filter, err := getItemFilter(&config{Options: tc.opts, dates: &dateReader{sources: []timeSource{timeSourceModify}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for _, entry := range entries {
+			if filter.match(dirItem{dir: dir, entry: entry}) {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("FAIL => Input: %+v, Expected: %v - Actual: %v", tc.opts, tc.expected, names)
+		}
+	}
+}
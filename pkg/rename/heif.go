@@ -0,0 +1,136 @@
+package rename
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// getHEIFSize reads the size of the primary image of HEIC, HEIF and AVIF files from its
+// image spatial extents ("ispe") property, rotated by its "irot" property
+func getHEIFSize(r io.ReaderAt, size int64) (int, int, bool) {
+	ipco, err := findBox(r, size, "meta", "iprp", "ipco")
+	if err != nil {
+		return 0, 0, false
+	}
+
+	properties, _ := readBoxes(r, ipco.offset, ipco.offset+ipco.size)
+	primary := getHEIFPrimaryProperties(r, size)
+
+	var width, height uint32
+	rotated := false
+
+	for i, property := range properties {
+		// Property indexes start at 1
+		if primary != nil && !primary[i+1] {
+			continue
+		}
+
+		data, err := readBoxPayload(r, property, 12)
+		if err != nil {
+			continue
+		}
+
+		switch property.typ {
+		case "ispe":
+			if len(data) < 12 {
+				continue
+			}
+			// Without the associations, the largest image is the primary one, the others are
+			// thumbnails or grid tiles
+			w, h := binary.BigEndian.Uint32(data[4:8]), binary.BigEndian.Uint32(data[8:12])
+			if uint64(w)*uint64(h) > uint64(width)*uint64(height) {
+				width, height = w, h
+			}
+		case "irot":
+			// Rotation is a multiple of 90° anti-clockwise
+			if primary != nil && len(data) > 0 {
+				rotated = data[0]&0x03%2 == 1
+			}
+		}
+	}
+
+	if width == 0 || height == 0 {
+		return 0, 0, false
+	}
+	if rotated {
+		width, height = height, width
+	}
+	return int(width), int(height), true
+}
+
+// getHEIFPrimaryProperties returns the indexes of the properties associated with the primary
+// item ("pitm") in the "ipma" box, nil when they can't be read
+func getHEIFPrimaryProperties(r io.ReaderAt, size int64) map[int]bool {
+	pitm, err := findBox(r, size, "meta", "pitm")
+	if err != nil {
+		return nil
+	}
+
+	data, err := readBoxPayload(r, pitm, 8)
+	if err != nil || len(data) < 4 {
+		return nil
+	}
+
+	idSize := 2
+	if data[0] > 0 {
+		idSize = 4
+	}
+	primaryID, _, ok := readUint(data[4:], idSize)
+	if !ok {
+		return nil
+	}
+
+	ipma, err := findBox(r, size, "meta", "iprp", "ipma")
+	if err != nil {
+		return nil
+	}
+
+	data, err = readBoxPayload(r, ipma, maxExifSize)
+	if err != nil || len(data) < 4 {
+		return nil
+	}
+
+	version, flags := data[0], data[3]
+	idSize, associationSize := 2, 1
+	if version > 0 {
+		idSize = 4
+	}
+	if flags&1 == 1 {
+		associationSize = 2
+	}
+
+	entryCount, rest, ok := readUint(data[4:], 4)
+	if !ok {
+		return nil
+	}
+
+	for i := uint64(0); i < entryCount; i++ {
+		var id uint64
+		if id, rest, ok = readUint(rest, idSize); !ok || len(rest) < 1 {
+			return nil
+		}
+
+		count := int(rest[0])
+		rest = rest[1:]
+		if len(rest) < count*associationSize {
+			return nil
+		}
+
+		if id == primaryID {
+			indexes := make(map[int]bool, count)
+			for j := 0; j < count; j++ {
+				// The highest bit tells whether the property is essential
+				if associationSize == 2 {
+					indexes[int(binary.BigEndian.Uint16(rest[j*2:])&0x7FFF)] = true
+				} else {
+					indexes[int(rest[j]&0x7F)] = true
+				}
+			}
+			return indexes
+		}
+
+		rest = rest[count*associationSize:]
+	}
+
+	return nil
+}
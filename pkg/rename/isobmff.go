@@ -0,0 +1,122 @@
+package rename
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// box is an ISO base media file format box (MP4, MOV, HEIC, AVIF...), offset and size
+// describe its payload, without the header
+type box struct {
+	typ          string
+	offset, size int64
+}
+
+var errBoxNotFound = errors.New("box not found")
+
+// readBoxes lists the boxes stored between start and end
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	boxes := []box{}
+	header := make([]byte, 16)
+
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return boxes, err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0: // Box extends to the end of its parent
+			size = end - offset
+		case 1: // 64-bit size follows the type
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return boxes, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if size < headerSize || offset+size > end {
+			return boxes, errors.New("invalid box size")
+		}
+
+		boxes = append(boxes, box{typ: typ, offset: offset + headerSize, size: size - headerSize})
+		offset += size
+	}
+
+	return boxes, nil
+}
+
+// childrenStart returns where the children of b start. HEIF "meta" is a full box (4 bytes of
+// version & flags before its children) while in QuickTime it's a plain container
+func childrenStart(r io.ReaderAt, b box) int64 {
+	if b.typ != "meta" || b.size < 4 {
+		return b.offset
+	}
+
+	versionFlags := make([]byte, 4)
+	if _, err := r.ReadAt(versionFlags, b.offset); err == nil && binary.BigEndian.Uint32(versionFlags) == 0 {
+		return b.offset + 4
+	}
+	return b.offset
+}
+
+// findBox walks down the given box types, e.g. findBox(r, size, "moov", "mvhd")
+func findBox(r io.ReaderAt, size int64, path ...string) (box, error) {
+	parent := box{offset: 0, size: size}
+
+	for i, typ := range path {
+		start := parent.offset
+		if i > 0 {
+			start = childrenStart(r, parent)
+		}
+
+		boxes, _ := readBoxes(r, start, parent.offset+parent.size)
+
+		found := false
+		for _, b := range boxes {
+			if b.typ == typ {
+				parent, found = b, true
+				break
+			}
+		}
+		if !found {
+			return box{}, errBoxNotFound
+		}
+	}
+
+	return parent, nil
+}
+
+// readBoxPayload reads at most limit bytes of the box payload
+func readBoxPayload(r io.ReaderAt, b box, limit int64) ([]byte, error) {
+	size := min(b.size, limit)
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, b.offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readUint reads a big-endian unsigned integer of 0, 2, 4 or 8 bytes
+func readUint(data []byte, size int) (uint64, []byte, bool) {
+	if len(data) < size {
+		return 0, data, false
+	}
+
+	switch size {
+	case 0:
+		return 0, data, true
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], true
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], true
+	case 8:
+		return binary.BigEndian.Uint64(data), data[8:], true
+	}
+	return 0, data, false
+}
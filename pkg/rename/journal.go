@@ -0,0 +1,163 @@
+package rename
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+)
+
+const journalExt = ".json"
+
+// Journal records the renames of a run so it can be reverted with Undo
+type Journal struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Path      string    `json:"path"`
+	Args      []string  `json:"args"`
+	// Command line that applied the saved plan built by Args, empty when it was applied right away
+	ApplyArgs []string       `json:"applyArgs,omitempty"`
+	Entries   []JournalEntry `json:"entries"`
+	// Directories created for the new paths, parents first. Undo removes the empty ones
+	Dirs     []string   `json:"dirs,omitempty"`
+	UndoneAt *time.Time `json:"undoneAt,omitempty"`
+}
+
+// JournalEntry is a successful rename, or a change of the times of a file when both paths are
+// the same. Size and mod time are taken from the new path right
+// after renaming, undo uses them to detect files that have been changed since
+type JournalEntry struct {
+	OldPath string    `json:"oldPath"`
+	NewPath string    `json:"newPath"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Times of the file before the run changed them, restored by undo
+	OldModTime    *time.Time `json:"oldModTime,omitempty"`
+	OldAccessTime *time.Time `json:"oldAccessTime,omitempty"`
+}
+
+// StateDir returns where journals are stored: custom dir > $XDG_STATE_HOME > ~/.local/state
+func StateDir(custom string) (string, error) {
+	if custom != "" {
+		return custom, nil
+	}
+
+	if stateHome := os.Getenv("XDG_STATE_HOME"); stateHome != "" {
+		return filepath.Join(stateHome, "dyno-clis", appName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".local", "state", "dyno-clis", appName), nil
+}
+
+func newJournal(path string, args []string) *Journal {
+	return &Journal{
+		// xid is sortable by creation time, so the file names keep the run order
+		ID:        utils.GenUniqueStr(),
+		CreatedAt: time.Now(),
+		Path:      path,
+		Args:      args,
+		Entries:   []JournalEntry{},
+	}
+}
+
+func (j *Journal) add(oldPath, newPath string) {
+	entry := JournalEntry{OldPath: oldPath, NewPath: newPath}
+	if info, err := os.Lstat(newPath); err == nil {
+		entry.Size, entry.ModTime = info.Size(), info.ModTime()
+	}
+	j.Entries = append(j.Entries, entry)
+}
+
+func (j *Journal) save(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", stateDir, err)
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(stateDir, j.ID+journalExt), data, 0644)
+}
+
+// saveTo saves the journal in the custom state directory, or the default one when empty
+func (j *Journal) saveTo(custom string) error {
+	stateDir, err := StateDir(custom)
+	if err != nil {
+		return err
+	}
+	return j.save(stateDir)
+}
+
+// ReadJournal reads the journal of the run with the given id
+func ReadJournal(stateDir, id string) (*Journal, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, id+journalExt))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("journal %s not found in %s", id, stateDir)
+		}
+		return nil, err
+	}
+
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", id, err)
+	}
+	return &j, nil
+}
+
+// Journals returns all journals, newest first. Journals that can't be read are left out
+func Journals(stateDir string) ([]*Journal, error) {
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*Journal{}, nil
+		}
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == journalExt {
+			ids = append(ids, strings.TrimSuffix(entry.Name(), journalExt))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	journals := make([]*Journal, 0, len(ids))
+	for _, id := range ids {
+		j, err := ReadJournal(stateDir, id)
+		if err != nil {
+			continue
+		}
+		journals = append(journals, j)
+	}
+	return journals, nil
+}
+
+// LastJournal returns the newest journal that hasn't been undone yet
+func LastJournal(stateDir string) (*Journal, error) {
+	journals, err := Journals(stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, j := range journals {
+		if j.UndoneAt == nil {
+			return j, nil
+		}
+	}
+	return nil, errors.New("no run to undo")
+}
@@ -0,0 +1,105 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndoJournal(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, ".state")
+
+	j := newJournal(dir, []string{"--prefix", "P"})
+	for _, name := range []string{"restored.jpg", "changed.jpg", "occupied.jpg", "missing.jpg"} {
+		oldPath, newPath := filepath.Join(dir, name), filepath.Join(dir, "P_"+name)
+		if err := os.WriteFile(newPath, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		j.add(oldPath, newPath)
+	}
+
+	if err := j.save(stateDir); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "P_changed.jpg"), later, later)
+	os.WriteFile(filepath.Join(dir, "occupied.jpg"), []byte("new file"), 0644)
+	os.Remove(filepath.Join(dir, "P_missing.jpg"))
+
+	saved, err := LastJournal(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]UndoStatus{
+		"P_restored.jpg": UndoRestored,
+		"P_changed.jpg":  UndoChanged,
+		"P_occupied.jpg": UndoOccupied,
+		"P_missing.jpg":  UndoMissing,
+	}
+
+	for _, r := range undoJournal(saved, false, false) {
+		name := filepath.Base(r.Entry.NewPath)
+		if r.Status != expected[name] {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", name, expected[name], r.Status)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "restored.jpg")); err != nil {
+		t.Errorf("FAIL => restored.jpg was not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "P_changed.jpg")); err != nil {
+		t.Errorf("FAIL => P_changed.jpg must be left untouched: %v", err)
+	}
+
+	// Forcing only restores the changed file, the occupied path is never overwritten
+	for _, r := range undoJournal(saved, true, false) {
+		name := filepath.Base(r.Entry.NewPath)
+		if name == "P_changed.jpg" && r.Status != UndoRestored {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", name, UndoRestored, r.Status)
+		}
+		if name == "P_occupied.jpg" && r.Status != UndoOccupied {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", name, UndoOccupied, r.Status)
+		}
+	}
+}
+
+func TestUndoUnrecoverable(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, ".state")
+
+	older := newJournal(dir, []string{"--prefix", "O"})
+	if err := older.save(stateDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ids are sortable by creation time, the second journal is the newest
+	j := newJournal(dir, []string{"--prefix", "P"})
+	for _, name := range []string{"occupied.jpg", "missing.jpg"} {
+		writeFiles(t, dir, "P_"+name)
+		j.add(filepath.Join(dir, name), filepath.Join(dir, "P_"+name))
+	}
+	os.Remove(filepath.Join(dir, "P_missing.jpg"))
+	writeFiles(t, dir, "occupied.jpg")
+
+	// The occupied path can be sorted out, the run is kept
+	if _, err := Undo(j, UndoOptions{StateDir: stateDir}); err != nil {
+		t.Fatal(err)
+	}
+	if last, err := LastJournal(stateDir); err != nil || last.ID != j.ID || len(last.Entries) != 2 {
+		t.Fatalf("FAIL => Expected: run %s with 2 entries left - Actual: '%v', '%v'", j.ID, last, err)
+	}
+
+	// Only the missing file is left, the run is done and the older one is next
+	os.Remove(filepath.Join(dir, "occupied.jpg"))
+	results, err := Undo(j, UndoOptions{StateDir: stateDir})
+	if err != nil || len(results) != 2 || j.UndoneAt == nil || len(j.Entries) != 1 {
+		t.Fatalf("FAIL => Expected: run undone with the missing entry left - Actual: '%v', '%v', '%v'", results, j.UndoneAt, err)
+	}
+	if last, err := LastJournal(stateDir); err != nil || last.ID != older.ID {
+		t.Errorf("FAIL => Expected: run %s - Actual: '%v', '%v'", older.ID, last, err)
+	}
+}
@@ -0,0 +1,174 @@
+package rename
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// EBML element ids used to find the video size of MKV and WebM files
+const (
+	ebmlIDHeader      = 0x1A45DFA3
+	ebmlIDSegment     = 0x18538067
+	ebmlIDTracks      = 0x1654AE6B
+	ebmlIDTrackEntry  = 0xAE
+	ebmlIDTrackType   = 0x83
+	ebmlIDVideo       = 0xE0
+	ebmlIDPixelWidth  = 0xB0
+	ebmlIDPixelHeight = 0xBA
+
+	ebmlTrackTypeVideo = 1
+)
+
+// ebmlElement describes the payload of an element, size is -1 when it is unknown (live streams)
+type ebmlElement struct {
+	id           uint64
+	offset, size int64
+}
+
+var errInvalidEBML = errors.New("invalid EBML element")
+
+// readVint reads an EBML variable length integer, ids keep their length marker
+func readVint(data []byte, keepMarker bool) (uint64, int, bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+
+	length := bits.LeadingZeros8(data[0]) + 1
+	if length > 8 || len(data) < length {
+		return 0, 0, false
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= 0xFF >> length
+	}
+	for i := 1; i < length; i++ {
+		value = value<<8 | uint64(data[i])
+	}
+
+	return value, length, true
+}
+
+func readEBMLElement(r io.ReaderAt, offset int64) (ebmlElement, error) {
+	header := make([]byte, 12)
+	n, err := r.ReadAt(header, offset)
+	if n == 0 {
+		return ebmlElement{}, err
+	}
+	header = header[:n]
+
+	id, idLength, ok := readVint(header, true)
+	if !ok {
+		return ebmlElement{}, errInvalidEBML
+	}
+
+	size, sizeLength, ok := readVint(header[idLength:], false)
+	if !ok {
+		return ebmlElement{}, errInvalidEBML
+	}
+
+	element := ebmlElement{id: id, offset: offset + int64(idLength+sizeLength), size: int64(size)}
+	// All the bits set means unknown size
+	if size == 1<<(7*sizeLength)-1 {
+		element.size = -1
+	}
+	return element, nil
+}
+
+// readEBMLChildren lists the elements of a parent with a known size
+func readEBMLChildren(r io.ReaderAt, parent ebmlElement) []ebmlElement {
+	children := []ebmlElement{}
+
+	for offset := parent.offset; offset < parent.offset+parent.size; {
+		child, err := readEBMLElement(r, offset)
+		if err != nil || child.size < 0 {
+			break
+		}
+		children = append(children, child)
+		offset = child.offset + child.size
+	}
+
+	return children
+}
+
+func readEBMLUint(r io.ReaderAt, element ebmlElement) uint64 {
+	if element.size <= 0 || element.size > 8 {
+		return 0
+	}
+
+	data := make([]byte, element.size)
+	if _, err := r.ReadAt(data, element.offset); err != nil {
+		return 0
+	}
+
+	value := uint64(0)
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// getMatroskaSize reads the pixel size of the first video track of MKV and WebM files
+func getMatroskaSize(r io.ReaderAt, size int64) (int, int, bool) {
+	header, err := readEBMLElement(r, 0)
+	if err != nil || header.id != ebmlIDHeader || header.size < 0 {
+		return 0, 0, false
+	}
+
+	segment, err := readEBMLElement(r, header.offset+header.size)
+	if err != nil || segment.id != ebmlIDSegment {
+		return 0, 0, false
+	}
+
+	end := size
+	if segment.size >= 0 {
+		end = min(end, segment.offset+segment.size)
+	}
+
+	// Tracks are stored before the clusters in practice, stop at the first element of unknown size
+	for offset := segment.offset; offset < end; {
+		element, err := readEBMLElement(r, offset)
+		if err != nil || element.size < 0 {
+			break
+		}
+
+		if element.id == ebmlIDTracks {
+			return getMatroskaTrackSize(r, element)
+		}
+		offset = element.offset + element.size
+	}
+
+	return 0, 0, false
+}
+
+func getMatroskaTrackSize(r io.ReaderAt, tracks ebmlElement) (int, int, bool) {
+	for _, entry := range readEBMLChildren(r, tracks) {
+		if entry.id != ebmlIDTrackEntry {
+			continue
+		}
+
+		var width, height, trackType uint64
+		for _, child := range readEBMLChildren(r, entry) {
+			switch child.id {
+			case ebmlIDTrackType:
+				trackType = readEBMLUint(r, child)
+			case ebmlIDVideo:
+				for _, video := range readEBMLChildren(r, child) {
+					switch video.id {
+					case ebmlIDPixelWidth:
+						width = readEBMLUint(r, video)
+					case ebmlIDPixelHeight:
+						height = readEBMLUint(r, video)
+					}
+				}
+			}
+		}
+
+		if trackType == ebmlTrackTypeVideo && width > 0 && height > 0 {
+			return int(width), int(height), true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,347 @@
+package rename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+)
+
+const (
+	conflictSkip      = "skip"
+	conflictSuffix    = "suffix"
+	conflictOverwrite = "overwrite"
+	conflictAbort     = "abort"
+
+	// Conflicts resolved this way are allowed by Apply
+	resolutionOverwritten = "overwritten"
+)
+
+var conflictPolicies = map[string]bool{
+	conflictSkip:      true,
+	conflictSuffix:    true,
+	conflictOverwrite: true,
+	conflictAbort:     true,
+}
+
+// ErrConflict is wrapped by the errors of plans whose renames target existing files
+var ErrConflict = errors.New("planned renames target existing files")
+
+// renameOp is a single os.Rename call of the plan
+type renameOp struct {
+	oldPath, newPath string
+}
+
+// Conflict is a planned rename whose target is already taken, resolution tells what the
+// conflict policy did with it
+type Conflict struct {
+	OldPath    string `json:"oldPath"`
+	NewPath    string `json:"newPath"`
+	Reason     string `json:"reason"`
+	Resolution string `json:"resolution"`
+
+	// A file is in the way of the directory of the target, only skipping the rename solves it
+	isParent bool
+}
+
+func pathDepth(path string) int {
+	return strings.Count(path, string(filepath.Separator))
+}
+
+func isInside(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// fileParent returns the first parent of the target that is a file, or that a file is
+// planned to move to, empty when the directory of the target can be created
+func fileParent(newPath string, renamed map[string]string) string {
+	for dir := filepath.Dir(newPath); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if oldPath, planned := renamed[dir]; planned {
+			if info, err := os.Lstat(oldPath); err != nil || !info.IsDir() {
+				return dir
+			}
+		}
+
+		// The parents of an existing directory exist too
+		if info, err := os.Lstat(dir); err == nil {
+			if !info.IsDir() {
+				return dir
+			}
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// findConflicts checks the planned targets against the files on disk. A target is free when
+// nothing exists there, when its file is renamed away by the plan, or when it is the file
+// itself (case-only rename on a case-insensitive filesystem). A target whose directory can't
+// be created because a file is in the way is a conflict too
+func findConflicts(renamed map[string]string) []Conflict {
+	sources := make(map[string]bool, len(renamed))
+	for _, oldPath := range renamed {
+		sources[oldPath] = true
+	}
+
+	conflicts := []Conflict{}
+	for newPath, oldPath := range renamed {
+		if parent := fileParent(newPath, renamed); parent != "" {
+			conflicts = append(conflicts, Conflict{OldPath: oldPath, NewPath: newPath, Reason: parent + " is not a directory", isParent: true})
+			continue
+		}
+
+		if sources[newPath] {
+			continue
+		}
+
+		targetInfo, err := os.Lstat(newPath)
+		if err != nil {
+			continue
+		}
+
+		if sourceInfo, err := os.Lstat(oldPath); err == nil && os.SameFile(sourceInfo, targetInfo) {
+			continue
+		}
+
+		conflicts = append(conflicts, Conflict{OldPath: oldPath, NewPath: newPath, Reason: "target already exists"})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].OldPath < conflicts[j].OldPath })
+	return conflicts
+}
+
+func getTempPath(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s-%s%s", appName, utils.GenUniqueStr(), filepath.Ext(path)))
+}
+
+// getFreePath adds a unique suffix to the name until nothing exists at the path and no other
+// planned rename targets it
+func getFreePath(path, separator string, renamed map[string]string) string {
+	for {
+		freePath := filepath.Join(filepath.Dir(path), withUniqueSuffix(filepath.Base(path), separator))
+		if _, planned := renamed[freePath]; planned {
+			continue
+		}
+		if _, err := os.Lstat(freePath); err != nil {
+			return freePath
+		}
+	}
+}
+
+// resolveConflicts applies the conflict policy to the plan and returns every conflict found.
+// Skipping a rename keeps its file in place, which can create new conflicts, so the plan is
+// checked again until it is stable
+func resolveConflicts(renamed map[string]string, policy, separator string) ([]Conflict, error) {
+	resolved := []Conflict{}
+	overwritten := map[string]bool{}
+
+	for {
+		conflicts := []Conflict{}
+		for _, c := range findConflicts(renamed) {
+			if !overwritten[c.NewPath] {
+				conflicts = append(conflicts, c)
+			}
+		}
+		if len(conflicts) == 0 {
+			break
+		}
+
+		for i, c := range conflicts {
+			switch {
+			case policy == conflictSkip, c.isParent && policy != conflictAbort:
+				delete(renamed, c.NewPath)
+				conflicts[i].Resolution = "skipped"
+			case policy == conflictSuffix:
+				freePath := getFreePath(c.NewPath, separator, renamed)
+				delete(renamed, c.NewPath)
+				renamed[freePath] = c.OldPath
+				conflicts[i].Resolution = "renamed to " + filepath.Base(freePath)
+			case policy == conflictOverwrite:
+				overwritten[c.NewPath] = true
+				conflicts[i].Resolution = resolutionOverwritten
+			default:
+				conflicts[i].Resolution = "aborted"
+			}
+		}
+		resolved = append(resolved, conflicts...)
+
+		if policy == conflictAbort {
+			return resolved, fmt.Errorf("%d %w", len(resolved), ErrConflict)
+		}
+	}
+
+	return resolved, nil
+}
+
+// orderRenames returns the os.Rename calls in dependency order:
+//   - a file is renamed after the file currently holding its target (a→b after b→c)
+//   - entries inside a directory are renamed before the directory itself
+//   - cycles (a→b, b→a) are broken by moving one file to a temporary name first
+func orderRenames(renamed map[string]string) []renameOp {
+	pending := make([]renameOp, 0, len(renamed))
+	for newPath, oldPath := range renamed {
+		if newPath != oldPath {
+			pending = append(pending, renameOp{oldPath: oldPath, newPath: newPath})
+		}
+	}
+
+	// Deepest first, then by name, so the order is the same on every run
+	sort.Slice(pending, func(i, j int) bool {
+		if di, dj := pathDepth(pending[i].oldPath), pathDepth(pending[j].oldPath); di != dj {
+			return di > dj
+		}
+		return pending[i].oldPath < pending[j].oldPath
+	})
+
+	ops := make([]renameOp, 0, len(pending))
+
+	for len(pending) > 0 {
+		sources := make(map[string]bool, len(pending))
+		for _, op := range pending {
+			sources[op.oldPath] = true
+		}
+
+		isBlockedByChild := func(op renameOp) bool {
+			for _, other := range pending {
+				if isInside(other.oldPath, op.oldPath) {
+					return true
+				}
+			}
+			return false
+		}
+
+		blocked := make([]renameOp, 0, len(pending))
+		for _, op := range pending {
+			if sources[op.newPath] || isBlockedByChild(op) {
+				blocked = append(blocked, op)
+				continue
+			}
+			ops = append(ops, op)
+		}
+
+		// Every remaining op waits for another one: there is a cycle, move one of its files to
+		// a temporary name to free its source
+		if len(blocked) == len(pending) {
+			i, found := findCycleOp(blocked, isBlockedByChild)
+			if !found {
+				// Can't happen with unique targets, keep the remaining ops rather than looping forever
+				return append(ops, blocked...)
+			}
+
+			op := blocked[i]
+			tempPath := getTempPath(op.oldPath)
+			ops = append(ops, renameOp{oldPath: op.oldPath, newPath: tempPath})
+			blocked[i] = renameOp{oldPath: tempPath, newPath: op.newPath}
+		}
+
+		pending = blocked
+	}
+
+	return ops
+}
+
+// findCycleOp returns an op that is part of a cycle of targets (a→b, b→c, c→a) and doesn't
+// wait for entries inside it
+func findCycleOp(ops []renameOp, isBlockedByChild func(renameOp) bool) (int, bool) {
+	bySource := make(map[string]int, len(ops))
+	for i, op := range ops {
+		bySource[op.oldPath] = i
+	}
+
+	for start := range ops {
+		// Follow the targets, the first op seen twice is inside a cycle
+		seen := map[int]bool{}
+		i, ok := start, true
+		for ok && !seen[i] {
+			seen[i] = true
+			i, ok = bySource[ops[i].newPath]
+		}
+		if !ok {
+			continue
+		}
+
+		for j := i; ; {
+			if !isBlockedByChild(ops[j]) {
+				return j, true
+			}
+			if j = bySource[ops[j].newPath]; j == i {
+				break
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// mkdirAll creates dir and its missing parents, and returns the directories it created,
+// parents first
+func mkdirAll(dir string) (created []string, err error) {
+	missing := []string{}
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append([]string{d}, missing...)
+	}
+
+	return missing, os.MkdirAll(dir, 0755)
+}
+
+// removeDirs removes the directories in reverse order, the ones that are not empty are kept
+func removeDirs(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// applyOps runs the ops in order, creating the missing directories of the targets. When one
+// fails, the ops already done are reverted so the directory is left as it was, the ops that
+// could not be reverted are returned as applied
+func applyOps(ops []renameOp) (applied []renameOp, createdDirs []string, err error) {
+	applied = make([]renameOp, 0, len(ops))
+	createdDirs = []string{}
+
+	var rollback = func(failedOp error) ([]renameOp, []string, error) {
+		notReverted, err := rollbackOps(applied, failedOp)
+		removeDirs(createdDirs)
+		return notReverted, createdDirs, err
+	}
+
+	for _, op := range ops {
+		// Moves may target a directory that doesn't exist yet, e.g. --dedupe move or a template
+		// with path separators
+		created, err := mkdirAll(filepath.Dir(op.newPath))
+		createdDirs = append(createdDirs, created...)
+		if err != nil {
+			return rollback(fmt.Errorf("failed to create the directory of %s: %w", op.newPath, err))
+		}
+
+		if err := os.Rename(op.oldPath, op.newPath); err != nil {
+			return rollback(fmt.Errorf("failed to rename %s ➡️  %s: %w", op.oldPath, op.newPath, err))
+		}
+		applied = append(applied, op)
+	}
+
+	return applied, createdDirs, nil
+}
+
+// rollbackOps reverts the applied ops after failedOp, the rollback failures are joined to it
+func rollbackOps(applied []renameOp, failedOp error) (notReverted []renameOp, err error) {
+	notReverted = []renameOp{}
+	errs := []error{failedOp}
+
+	for i := len(applied) - 1; i >= 0; i-- {
+		op := applied[i]
+		if err := os.Rename(op.newPath, op.oldPath); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s ➡️  %s: %w", op.newPath, op.oldPath, err))
+			notReverted = append([]renameOp{op}, notReverted...)
+		}
+	}
+
+	return notReverted, errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readContent(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func TestOrderRenames(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []string
+		plan  map[string]string // new name => old name
+	}{
+		{name: "chain", files: []string{"a", "b", "c"}, plan: map[string]string{"b": "a", "c": "b", "d": "c"}},
+		{name: "swap", files: []string{"a", "b"}, plan: map[string]string{"b": "a", "a": "b"}},
+		{name: "cycle", files: []string{"a", "b", "c"}, plan: map[string]string{"b": "a", "c": "b", "a": "c"}},
+		{name: "cycle with tail", files: []string{"a", "b", "x"}, plan: map[string]string{"b": "a", "a": "b", "y": "x"}},
+	}
+
+	for _, tc := range testCases {
+		dir := t.TempDir()
+		writeFiles(t, dir, tc.files...)
+
+		renamed := map[string]string{}
+		for newName, oldName := range tc.plan {
+			renamed[filepath.Join(dir, newName)] = filepath.Join(dir, oldName)
+		}
+
+		if conflicts := findConflicts(renamed); len(conflicts) > 0 {
+			t.Errorf("FAIL => Input: %v, Expected: no conflict - Actual: '%v'", tc.name, conflicts)
+		}
+
+		if _, _, err := applyOps(orderRenames(renamed)); err != nil {
+			t.Errorf("FAIL => Input: %v, Expected: no error - Actual: '%v'", tc.name, err)
+		}
+
+		for newName, oldName := range tc.plan {
+			if content := readContent(dir, newName); content != oldName {
+				t.Errorf("FAIL => Input: %v, Expected: %v has the content of '%v' - Actual: '%v'", tc.name, newName, oldName, content)
+			}
+		}
+	}
+}
+
+func TestOrderRenamesDirectories(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	writeFiles(t, dir, "sub/a")
+
+	renamed := map[string]string{
+		filepath.Join(dir, "sub2"):     filepath.Join(dir, "sub"),
+		filepath.Join(dir, "sub", "b"): filepath.Join(dir, "sub", "a"),
+	}
+
+	if _, _, err := applyOps(orderRenames(renamed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readContent(dir, "sub2/b"); content != "sub/a" {
+		t.Errorf("FAIL => Expected: sub2/b has the content of 'sub/a' - Actual: '%v'", content)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a", "b", "taken")
+
+	renamed := map[string]string{
+		filepath.Join(dir, "taken"): filepath.Join(dir, "a"),
+		filepath.Join(dir, "a"):     filepath.Join(dir, "b"),
+	}
+
+	conflicts := findConflicts(renamed)
+	if len(conflicts) != 1 || conflicts[0].NewPath != filepath.Join(dir, "taken") {
+		t.Errorf("FAIL => Expected: one conflict on 'taken' - Actual: '%v'", conflicts)
+	}
+}
+
+func TestApplyOpsRollback(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a", "b", "file")
+
+	// Missing directories are created, but not below a file
+	ops := []renameOp{
+		{oldPath: filepath.Join(dir, "a"), newPath: filepath.Join(dir, "c")},
+		{oldPath: filepath.Join(dir, "b"), newPath: filepath.Join(dir, "file", "b")},
+	}
+
+	applied, _, err := applyOps(ops)
+	if err == nil || len(applied) != 0 {
+		t.Errorf("FAIL => Expected: error and nothing applied - Actual: '%v', '%v'", err, applied)
+	}
+
+	if readContent(dir, "a") != "a" || readContent(dir, "b") != "b" {
+		t.Errorf("FAIL => Expected: a and b rolled back")
+	}
+}
+
+func TestApplyOpsCreatesDirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a")
+
+	ops := []renameOp{{oldPath: filepath.Join(dir, "a"), newPath: filepath.Join(dir, "x", "y", "a")}}
+	_, created, err := applyOps(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if readContent(filepath.Join(dir, "x", "y"), "a") != "a" {
+		t.Errorf("FAIL => Expected: a moved to x/y")
+	}
+	if expected := []string{filepath.Join(dir, "x"), filepath.Join(dir, "x", "y")}; !reflect.DeepEqual(created, expected) {
+		t.Errorf("FAIL => Expected: created %v - Actual: %v", expected, created)
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a", "b", "taken")
+
+	// b → a only works if a is renamed away, skipping a → taken must skip b → a too
+	newPlan := func() map[string]string {
+		return map[string]string{
+			filepath.Join(dir, "taken"): filepath.Join(dir, "a"),
+			filepath.Join(dir, "a"):     filepath.Join(dir, "b"),
+		}
+	}
+
+	testCases := []struct {
+		policy        string
+		conflictCount int
+		renameCount   int
+		isError       bool
+	}{
+		{policy: conflictSkip, conflictCount: 2, renameCount: 0},
+		{policy: conflictSuffix, conflictCount: 1, renameCount: 2},
+		{policy: conflictOverwrite, conflictCount: 1, renameCount: 2},
+		{policy: conflictAbort, conflictCount: 1, renameCount: 2, isError: true},
+	}
+
+	for _, tc := range testCases {
+		renamed := newPlan()
+		conflicts, err := resolveConflicts(renamed, tc.policy, "_")
+
+		if len(conflicts) != tc.conflictCount || len(renamed) != tc.renameCount || (err != nil) != tc.isError {
+			t.Errorf("FAIL => Input: %v, Expected: %v conflicts, %v renames, error %v - Actual: %v, %v, %v",
+				tc.policy, tc.conflictCount, tc.renameCount, tc.isError, len(conflicts), len(renamed), err)
+		}
+	}
+}
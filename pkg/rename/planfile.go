@@ -0,0 +1,291 @@
+package rename
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	planStatusRename = "rename"
+	// A rename that overwrites its target, allowed by Apply
+	planStatusOverwrite = "overwrite"
+	planStatusSkip      = "skip"
+
+	planReasonRename = "new name"
+)
+
+var planCSVHeader = []string{"status", "old_path", "new_path", "size", "mod_time", "reason", "set_mod_time"}
+
+// Plan is the reviewable set of renames built by Build and run by Apply, it can be saved with
+// WritePlan. Size and mod time are taken from the source files, Apply refuses to run if they
+// changed
+type Plan struct {
+	CreatedAt time.Time `json:"createdAt"`
+	// Directory the plan was built in, and the command line that built it, set by the caller
+	Path      string     `json:"path"`
+	Args      []string   `json:"args"`
+	Renames   []Rename   `json:"renames"`
+	Conflicts []Conflict `json:"conflicts"`
+
+	// Only set by Build
+	Report *Report `json:"-"`
+}
+
+// Rename is a planned rename, sorted by old path in the plan
+type Rename struct {
+	OldPath string    `json:"oldPath"`
+	NewPath string    `json:"newPath"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Reason  string    `json:"reason"`
+	// Mod time given to the file once renamed, from the date in its name (Options.SetModTime)
+	SetModTime *time.Time `json:"setModTime,omitempty"`
+}
+
+// newPlan applies the conflict policy to the renames, new path => old path, and returns their
+// plan. With the abort policy, the plan is returned with the error
+func newPlan(path string, renamed map[string]string, c *config) (*Plan, error) {
+	conflicts, err := resolveConflicts(renamed, c.OnConflict, c.Separator)
+	plan := planOf(path, renamed, conflicts)
+
+	if c.SetModTime {
+		for i, r := range plan.Renames {
+			if date, ok := c.dates.filenameDate(filepath.Base(r.OldPath)); ok && !date.Equal(r.ModTime) {
+				plan.Renames[i].SetModTime = &date
+			}
+		}
+	}
+
+	return plan, err
+}
+
+// planOf returns the plan of the resolved renames, the reason of a rename tells how its
+// conflict was resolved
+func planOf(path string, renamed map[string]string, conflicts []Conflict) *Plan {
+	conflictBySource := make(map[string]Conflict, len(conflicts))
+	for _, c := range conflicts {
+		conflictBySource[c.OldPath] = c
+	}
+
+	plan := &Plan{CreatedAt: time.Now(), Path: path, Args: []string{}, Renames: []Rename{}, Conflicts: conflicts}
+
+	for _, newPath := range sortedRenames(renamed) {
+		entry := Rename{OldPath: renamed[newPath], NewPath: newPath, Reason: planReasonRename}
+		if info, err := os.Lstat(entry.OldPath); err == nil {
+			entry.Size, entry.ModTime = info.Size(), info.ModTime()
+		}
+		if c, ok := conflictBySource[entry.OldPath]; ok {
+			entry.Reason = fmt.Sprintf("%s, %s", c.Reason, c.Resolution)
+		}
+		plan.Renames = append(plan.Renames, entry)
+	}
+
+	return plan
+}
+
+// sortedRenames returns the new paths sorted by their old path
+func sortedRenames(renamed map[string]string) []string {
+	newPaths := make([]string, 0, len(renamed))
+	for newPath := range renamed {
+		newPaths = append(newPaths, newPath)
+	}
+
+	sort.Slice(newPaths, func(i, j int) bool {
+		return renamed[newPaths[i]] < renamed[newPaths[j]]
+	})
+
+	return newPaths
+}
+
+func isCSVPlan(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".csv")
+}
+
+func (p *Plan) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(planCSVHeader); err != nil {
+		return err
+	}
+
+	overwritten := map[string]bool{}
+	for _, c := range p.Conflicts {
+		if c.Resolution == resolutionOverwritten {
+			overwritten[c.OldPath] = true
+		}
+	}
+
+	for _, e := range p.Renames {
+		status := planStatusRename
+		if overwritten[e.OldPath] {
+			status = planStatusOverwrite
+		}
+
+		modTime, setModTime := "", ""
+		if !e.ModTime.IsZero() {
+			modTime = e.ModTime.Format(time.RFC3339Nano)
+		}
+		if e.SetModTime != nil {
+			setModTime = e.SetModTime.Format(time.RFC3339Nano)
+		}
+		writer.Write([]string{status, e.OldPath, e.NewPath, strconv.FormatInt(e.Size, 10), modTime, e.Reason, setModTime})
+	}
+
+	// Conflicts that are not renamed (skipped or aborted) are listed for review only
+	renamedSources := make(map[string]bool, len(p.Renames))
+	for _, e := range p.Renames {
+		renamedSources[e.OldPath] = true
+	}
+	for _, c := range p.Conflicts {
+		if !renamedSources[c.OldPath] {
+			writer.Write([]string{planStatusSkip, c.OldPath, c.NewPath, "", "", c.Reason + ", " + c.Resolution, ""})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func readPlanCSV(r io.Reader) (*Plan, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Plans written before set_mod_time was added don't have the last column
+	header := strings.Join(planCSVHeader, ",")
+	if len(records) == 0 || (strings.Join(records[0], ",") != header && strings.Join(records[0], ",") != strings.Join(planCSVHeader[:6], ",")) {
+		return nil, fmt.Errorf("invalid plan header, expected: %s", header)
+	}
+
+	plan := &Plan{Renames: []Rename{}, Conflicts: []Conflict{}}
+	for i, record := range records[1:] {
+		switch record[0] {
+		case planStatusRename:
+		case planStatusOverwrite, planStatusSkip:
+			plan.Conflicts = append(plan.Conflicts, conflictOfCSV(record))
+			if record[0] == planStatusSkip {
+				continue
+			}
+		default:
+			return nil, fmt.Errorf("line %d: invalid status %s", i+2, record[0])
+		}
+
+		entry := Rename{OldPath: record[1], NewPath: record[2], Reason: record[5]}
+		if entry.Size, err = strconv.ParseInt(record[3], 10, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %s", i+2, record[3])
+		}
+		if record[4] != "" {
+			if entry.ModTime, err = time.Parse(time.RFC3339Nano, record[4]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid mod time %s", i+2, record[4])
+			}
+		}
+		if len(record) > 6 && record[6] != "" {
+			setModTime, err := time.Parse(time.RFC3339Nano, record[6])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid mod time %s", i+2, record[6])
+			}
+			entry.SetModTime = &setModTime
+		}
+		plan.Renames = append(plan.Renames, entry)
+	}
+
+	return plan, nil
+}
+
+// conflictOfCSV returns the conflict of a skip or overwrite line, its reason column is
+// "reason, resolution"
+func conflictOfCSV(record []string) Conflict {
+	c := Conflict{OldPath: record[1], NewPath: record[2], Reason: record[5]}
+	if i := strings.LastIndex(record[5], ", "); i >= 0 {
+		c.Reason, c.Resolution = record[5][:i], record[5][i+2:]
+	}
+	return c
+}
+
+// WritePlan writes the plan as JSON, or CSV when the file ends with .csv. CSV plans keep the
+// renames, the overwritten targets and the skipped conflicts
+func WritePlan(filePath string, plan *Plan) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if isCSVPlan(filePath) {
+		return plan.writeCSV(f)
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(plan)
+}
+
+// ReadPlan reads a plan written by WritePlan
+func ReadPlan(filePath string) (*Plan, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if isCSVPlan(filePath) {
+		return readPlanCSV(f)
+	}
+
+	var plan Plan
+	if err := json.NewDecoder(f).Decode(&plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// CheckSources returns why each source can't be renamed anymore: missing, or its size or mod
+// time differ from when the plan was written
+func (p *Plan) CheckSources() []string {
+	problems := []string{}
+
+	for _, e := range p.Renames {
+		info, err := os.Lstat(e.OldPath)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: missing", e.OldPath))
+		case !info.IsDir() && (info.Size() != e.Size || !info.ModTime().Equal(e.ModTime)):
+			problems = append(problems, fmt.Sprintf("%s: changed since the plan was written", e.OldPath))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// renamed returns the renames of the plan as new path => old path, after checking that they
+// can be run: absolute paths, each file renamed once to its own target
+func (p *Plan) renamed() (map[string]string, error) {
+	renamed := make(map[string]string, len(p.Renames))
+	sources := make(map[string]bool, len(p.Renames))
+
+	for _, e := range p.Renames {
+		if !filepath.IsAbs(e.OldPath) || !filepath.IsAbs(e.NewPath) {
+			return nil, fmt.Errorf("%s ➡️  %s: paths must be absolute", e.OldPath, e.NewPath)
+		}
+		if _, exists := renamed[e.NewPath]; exists {
+			return nil, fmt.Errorf("%s is the target of more than one rename", e.NewPath)
+		}
+		if sources[e.OldPath] {
+			return nil, fmt.Errorf("%s is renamed more than once", e.OldPath)
+		}
+
+		renamed[e.NewPath], sources[e.OldPath] = e.OldPath, true
+	}
+
+	return renamed, nil
+}
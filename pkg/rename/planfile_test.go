@@ -0,0 +1,122 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "x_b.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renamed := map[string]string{
+		filepath.Join(dir, "x_a.jpg"): filepath.Join(dir, "a.jpg"),
+		filepath.Join(dir, "x_b.jpg"): filepath.Join(dir, "b.jpg"),
+	}
+	conflicts := []Conflict{
+		{OldPath: filepath.Join(dir, "b.jpg"), NewPath: filepath.Join(dir, "x_b.jpg"), Reason: "target exists", Resolution: "overwritten"},
+		{OldPath: filepath.Join(dir, "c.jpg"), NewPath: filepath.Join(dir, "x_c.jpg"), Reason: "target exists", Resolution: "skipped"},
+	}
+	plan := planOf(dir, renamed, conflicts)
+	setModTime := time.Date(2023, 5, 12, 10, 15, 30, 0, time.UTC)
+	plan.Renames[0].SetModTime = &setModTime
+
+	if plan.Renames[1].Reason != "target exists, overwritten" {
+		t.Errorf("FAIL => Expected: 'target exists, overwritten' - Actual: '%v'", plan.Renames[1].Reason)
+	}
+
+	var saved *Plan
+	for _, name := range []string{"plan.json", "plan.csv"} {
+		planPath := filepath.Join(dir, name)
+		if err := WritePlan(planPath, plan); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		if saved, err = ReadPlan(planPath); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		actual, err := saved.renamed()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(actual, renamed) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", name, renamed, actual)
+		}
+		if !reflect.DeepEqual(saved.Conflicts, conflicts) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", name, conflicts, saved.Conflicts)
+		}
+		if problems := saved.CheckSources(); len(problems) != 0 {
+			t.Errorf("FAIL => Input: %v, Expected: no problems - Actual: '%v'", name, problems)
+		}
+		// x_b.jpg exists, it is allowed as the overwritten target
+		if found, err := saved.FindConflicts(); err != nil || len(found) != 0 {
+			t.Errorf("FAIL => Input: %v, Expected: no conflicts - Actual: '%v', '%v'", name, found, err)
+		}
+		if m := saved.Renames[0].SetModTime; m == nil || !m.Equal(setModTime) || saved.Renames[1].SetModTime != nil {
+			t.Errorf("FAIL => Input: %v, Expected: '%v', nil - Actual: '%v', '%v'", name, setModTime, m, saved.Renames[1].SetModTime)
+		}
+	}
+
+	if _, err := Apply(saved, ApplyOptions{StateDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	if readContent(dir, "x_b.jpg") != "b.jpg" {
+		t.Errorf("FAIL => Expected: x_b.jpg overwritten by the CSV plan")
+	}
+}
+
+func TestCheckPlanSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"same.jpg", "changed.jpg", "missing.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renamed := map[string]string{}
+	for _, name := range []string{"same.jpg", "changed.jpg", "missing.jpg"} {
+		renamed[filepath.Join(dir, "new_"+name)] = filepath.Join(dir, name)
+	}
+	plan := planOf(dir, renamed, nil)
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "changed.jpg"), later, later)
+	os.Remove(filepath.Join(dir, "missing.jpg"))
+
+	expected := []string{
+		filepath.Join(dir, "changed.jpg") + ": changed since the plan was written",
+		filepath.Join(dir, "missing.jpg") + ": missing",
+	}
+	if problems := plan.CheckSources(); !reflect.DeepEqual(problems, expected) {
+		t.Errorf("FAIL => Expected: '%v' - Actual: '%v'", expected, problems)
+	}
+}
+
+func TestRenamedFromPlanErrors(t *testing.T) {
+	cases := map[string][]Rename{
+		"relative path": {{OldPath: "a.jpg", NewPath: "/tmp/b.jpg"}},
+		"same target": {
+			{OldPath: "/tmp/a.jpg", NewPath: "/tmp/c.jpg"},
+			{OldPath: "/tmp/b.jpg", NewPath: "/tmp/c.jpg"},
+		},
+		"same source": {
+			{OldPath: "/tmp/a.jpg", NewPath: "/tmp/b.jpg"},
+			{OldPath: "/tmp/a.jpg", NewPath: "/tmp/c.jpg"},
+		},
+	}
+
+	for name, entries := range cases {
+		if _, err := (&Plan{Renames: entries}).renamed(); err == nil {
+			t.Errorf("FAIL => Input: %v, Expected: error - Actual: nil", name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rename
+
+import (
+	"context"
+	"sync"
+)
+
+// runPool calls fn for each index in [0, total) on a pool of jobs workers, progress is told
+// about each index done. When ctx is cancelled no new index is handed out, the running calls
+// are finished and done tells which indexes have been processed
+func runPool(ctx context.Context, total, jobs int, progress func(done, total int), fn func(i int)) (done []bool) {
+	done = make([]bool, total)
+
+	var mu sync.Mutex
+	doneCount := 0
+	if progress != nil {
+		progress(0, total)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for range max(jobs, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+				done[i] = true
+
+				if progress != nil {
+					mu.Lock()
+					doneCount++
+					progress(doneCount, total)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range total {
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return done
+}
@@ -0,0 +1,104 @@
+package rename
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+// QuickTime & MP4 timestamps are seconds since 1904-01-01 UTC
+var quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// getQuickTimeDate reads the creation_time of the movie header (moov/mvhd) of MOV/MP4 files
+func getQuickTimeDate(filePath string) (time.Time, bool) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	mvhd, err := findBox(f, info.Size(), "moov", "mvhd")
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	data, err := readBoxPayload(f, mvhd, 12)
+	if err != nil || len(data) < 8 {
+		return time.Time{}, false
+	}
+
+	// Version 1 uses 64-bit times, version 0 uses 32-bit times, both after 4 bytes of version & flags
+	timeSize := 4
+	if data[0] == 1 {
+		timeSize = 8
+	}
+
+	seconds, _, ok := readUint(data[4:], timeSize)
+	// Many cameras leave the creation time empty
+	if !ok || seconds == 0 {
+		return time.Time{}, false
+	}
+
+	return quickTimeEpoch.Add(time.Duration(seconds) * time.Second).Local(), true
+}
+
+// getQuickTimeSize reads the display size of the first video track from its track header
+// (moov/trak/tkhd), swapped when the track matrix rotates it by 90° or 270°
+func getQuickTimeSize(r io.ReaderAt, size int64) (int, int, bool) {
+	moov, err := findBox(r, size, "moov")
+	if err != nil {
+		return 0, 0, false
+	}
+
+	traks, _ := readBoxes(r, moov.offset, moov.offset+moov.size)
+	for _, trak := range traks {
+		if trak.typ != "trak" {
+			continue
+		}
+
+		children, _ := readBoxes(r, trak.offset, trak.offset+trak.size)
+		for _, tkhd := range children {
+			if tkhd.typ != "tkhd" {
+				continue
+			}
+
+			data, err := readBoxPayload(r, tkhd, 96)
+			if err != nil || len(data) < 1 {
+				continue
+			}
+
+			// Version 1 uses 64-bit times and duration, the matrix is followed by the 16.16
+			// fixed-point width and height
+			matrixOffset := 40
+			if data[0] == 1 {
+				matrixOffset = 52
+			}
+			if len(data) < matrixOffset+44 {
+				continue
+			}
+
+			be := binary.BigEndian
+			width := be.Uint32(data[matrixOffset+36:]) >> 16
+			height := be.Uint32(data[matrixOffset+40:]) >> 16
+			// Audio tracks have no size
+			if width == 0 || height == 0 {
+				continue
+			}
+
+			// The matrix is {a, b, u, c, d, v, x, y, w}, rotating by 90° or 270° gives a = 0
+			a, b := int32(be.Uint32(data[matrixOffset:])), int32(be.Uint32(data[matrixOffset+4:]))
+			if a == 0 && b != 0 {
+				width, height = height, width
+			}
+			return int(width), int(height), true
+		}
+	}
+
+	return 0, 0, false
+}
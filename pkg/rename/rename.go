@@ -0,0 +1,165 @@
+// Package rename plans and applies batch renames of the files in a directory, the engine of
+// the renamer command. Build a plan with Build, review it, run it with Apply and revert it
+// with Undo. Nothing in this package prints or prompts
+package rename
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const (
+	// Names the state directory of the journals and the temporary files of cyclic renames
+	appName = "renamer"
+
+	suffixFlag        = "suffix"
+	emptyOverrideFlag = "<empty>"
+)
+
+// Options drives Build, each field matches the renamer flag of the same name. Start from
+// DefaultOptions, the zero value has no separator and no counter step
+type Options struct {
+	// Walk
+	Recursive bool
+	MaxDepth  int // <= 0 means no limit
+	AllowDir  bool
+	Hidden    bool
+	Symlinks  string // skip, link or target
+
+	// Filters
+	Include, Exclude    string // regexes matched on the file name
+	Globs, ExcludeGlobs []string
+	Kind                string // photo, video or both comma-separated
+	MinSize, MaxSize    string // e.g. 500, 10K, 1.5MB
+	After, Before       string // YYYY-MM-DD or YYYY-MM-DDTHH:mm:ss, compared to the file date
+
+	// Naming, Template can't be combined with the legacy naming fields
+	Template                      string
+	Prefix, Suffix, Override      string
+	Separator                     string
+	CreatedDate, DetectResolution string // prefix or suffix, the date is followed by its format
+	AspectRatio                   string // separator of the ratio added after the resolution
+	UniqueSuffix                  bool
+
+	Replace                           []string
+	ReplaceFile                       string
+	ReplaceLiteral, ReplaceIgnoreCase bool
+
+	DateSource        string   // comma-separated fallback order, e.g. exif,quicktime,mtime
+	FilenameDates     []string // regexes with named groups tried before the built-in patterns
+	SetModTime        bool     // set the mod time of the renamed files to the date in their name
+	SeqSort           string
+	SeqStart, SeqStep int
+
+	// Transforms
+	CaseStyle                                      string
+	ASCII, CollapseSpaces, SafeChars, NormalizeExt bool
+
+	OnConflict string // skip, suffix, overwrite or abort
+	Dedupe     string // empty, report, skip or move
+	DedupeDir  string // relative to the directory of the plan
+
+	// Number of files probed or hashed at the same time
+	Jobs int
+	// Called with (0, total) when a batch of files starts to be probed or hashed, then after
+	// each file. Calls are serialized
+	Progress func(done, total int)
+}
+
+// config is the validated form of Options
+type config struct {
+	Options
+	nameTemplate *nameTemplate
+	dates        *dateReader
+	filter       *itemFilter
+	replacer     *replacer
+}
+
+// DefaultOptions returns the defaults of the renamer command
+func DefaultOptions() Options {
+	return Options{
+		Separator:  "_",
+		Globs:      []string{},
+		Replace:    []string{},
+		Symlinks:   symlinkLink,
+		Jobs:       runtime.NumCPU(),
+		DateSource: defaultDateSources,
+		SeqStart:   1,
+		SeqStep:    1,
+		SeqSort:    seqSortNatural,
+		OnConflict: conflictAbort,
+		DedupeDir:  "duplicates",
+	}
+}
+
+// HasNamingOptions tells whether one of the legacy naming fields is set
+func (o *Options) HasNamingOptions() bool {
+	return o.Prefix != "" || o.Suffix != "" || o.Override != "" ||
+		o.CreatedDate != "" || o.DetectResolution != "" || o.AspectRatio != "" || o.UniqueSuffix
+}
+
+// HasTransformOptions tells whether the new names are transformed
+func (o *Options) HasTransformOptions() bool {
+	return o.CaseStyle != "" || o.ASCII || o.CollapseSpaces || o.SafeChars || o.NormalizeExt
+}
+
+// newConfig validates every option up front, before any file is read
+func newConfig(opts Options) (*config, error) {
+	c := &config{Options: opts}
+	var err error
+
+	if c.nameTemplate, err = getNameTemplate(&c.Options); err != nil {
+		return nil, err
+	}
+
+	if c.AspectRatio != "" && c.DetectResolution == "" {
+		return nil, fmt.Errorf("--aspect-ratio is added after the resolution, use it with --detect-resolution")
+	}
+
+	if err := validateCaseStyle(c.CaseStyle); err != nil {
+		return nil, err
+	}
+
+	if c.OnConflict == "" {
+		c.OnConflict = conflictAbort
+	}
+	if !conflictPolicies[c.OnConflict] {
+		return nil, fmt.Errorf("invalid conflict policy %s, expected: skip, suffix, overwrite or abort", c.OnConflict)
+	}
+
+	if c.Symlinks == "" {
+		c.Symlinks = symlinkLink
+	}
+	if !symlinkPolicies[c.Symlinks] {
+		return nil, fmt.Errorf("invalid symlink policy %s, expected: skip, link or target", c.Symlinks)
+	}
+
+	if c.Dedupe != "" && !dedupeModes[c.Dedupe] {
+		return nil, fmt.Errorf("invalid dedupe mode %s, expected: report, skip or move", c.Dedupe)
+	}
+
+	if c.SeqSort == "" {
+		c.SeqSort = seqSortNatural
+	}
+	if !seqSorts[c.SeqSort] {
+		return nil, fmt.Errorf("invalid sequence sort %s, expected: name, natural, mtime, date or size", c.SeqSort)
+	}
+
+	if c.DateSource == "" {
+		c.DateSource = defaultDateSources
+	}
+	if c.dates, err = newDateReader(c.DateSource, c.FilenameDates); err != nil {
+		return nil, err
+	}
+
+	if c.filter, err = getItemFilter(c); err != nil {
+		return nil, err
+	}
+
+	replaceOpts := replaceOptions{literal: c.ReplaceLiteral, ignoreCase: c.ReplaceIgnoreCase}
+	if c.replacer, err = getReplacer(c.Replace, c.ReplaceFile, replaceOpts); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
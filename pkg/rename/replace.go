@@ -0,0 +1,158 @@
+package rename
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// replaceRule is a single --replace rule, rules are applied in the order they are given
+type replaceRule struct {
+	regex       *regexp.Regexp
+	replacement string
+	literal     bool
+}
+
+type replacer struct {
+	rules []replaceRule
+}
+
+// replaceOptions apply to every rule, sed-style rules can also enable them one by one
+type replaceOptions struct {
+	literal, ignoreCase bool
+}
+
+func (r *replacer) replace(name string) string {
+	for _, rule := range r.rules {
+		if rule.literal {
+			name = rule.regex.ReplaceAllLiteralString(name, rule.replacement)
+		} else {
+			name = rule.regex.ReplaceAllString(name, rule.replacement)
+		}
+	}
+	return name
+}
+
+// splitUnescaped splits s on sep, "\<sep>" is kept as a literal sep
+func splitUnescaped(s string, sep byte) []string {
+	parts := []string{}
+	current := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == sep:
+			current.WriteByte(sep)
+			i++
+		case s[i] == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+
+	return append(parts, current.String())
+}
+
+// isSedRule tells whether the rule uses the s/old/new/flags syntax, any punctuation can be
+// the delimiter (s|old|new|) so slashes or equal signs don't need to be escaped
+func isSedRule(rule string) bool {
+	if len(rule) < 4 || rule[0] != 's' {
+		return false
+	}
+
+	delimiter := rule[1]
+	isPunct := strings.IndexByte("/|#,:;!@%~", delimiter) >= 0
+	if !isPunct {
+		return false
+	}
+
+	parts := splitUnescaped(rule[2:], delimiter)
+	return len(parts) == 3 && strings.Trim(parts[2], "il") == ""
+}
+
+// parseReplaceRule parses "old=new" (use \= for a literal =) or "s/old/new/flags" where the
+// flags are i (ignore case) and l (literal, no regex)
+func parseReplaceRule(rule string, opts replaceOptions) (replaceRule, error) {
+	var pattern, replacement string
+
+	if isSedRule(rule) {
+		parts := splitUnescaped(rule[2:], rule[1])
+		pattern, replacement = parts[0], parts[1]
+		opts.ignoreCase = opts.ignoreCase || strings.Contains(parts[2], "i")
+		opts.literal = opts.literal || strings.Contains(parts[2], "l")
+	} else {
+		parts := splitUnescaped(rule, '=')
+		if len(parts) != 2 {
+			return replaceRule{}, fmt.Errorf("invalid replace format: %s, expected: old=new (\\= for a literal =) or s/old/new/flags", rule)
+		}
+		pattern, replacement = parts[0], parts[1]
+	}
+
+	if pattern == "" {
+		return replaceRule{}, fmt.Errorf("invalid replace rule: %s, the text to replace is empty", rule)
+	}
+
+	if opts.literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return replaceRule{}, fmt.Errorf("failed to compile replace regex: %s, error: %v", pattern, err)
+	}
+
+	return replaceRule{regex: regex, replacement: replacement, literal: opts.literal}, nil
+}
+
+// readReplaceFile reads one rule per line, empty lines and lines starting with # are skipped
+func readReplaceFile(filePath string) ([]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+
+	return rules, scanner.Err()
+}
+
+// getReplacer builds the rules of the rules file first, then the --replace flags
+func getReplacer(rules []string, rulesFile string, opts replaceOptions) (*replacer, error) {
+	if rulesFile != "" {
+		fileRules, err := readReplaceFile(rulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read replace file: %w", err)
+		}
+		rules = append(fileRules, rules...)
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	r := &replacer{rules: make([]replaceRule, 0, len(rules))}
+	for _, rule := range rules {
+		parsed, err := parseReplaceRule(rule, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, parsed)
+	}
+
+	return r, nil
+}
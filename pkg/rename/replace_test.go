@@ -0,0 +1,62 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplacer(t *testing.T) {
+	testCases := []struct {
+		rules    []string
+		opts     replaceOptions
+		name     string
+		expected string
+	}{
+		{rules: []string{"old=new"}, name: "oldfile", expected: "newfile"},
+		{rules: []string{`IMG_(\d+)=Photo-$1`}, name: "IMG_001", expected: "Photo-001"},
+		{rules: []string{"IMG=Photo", "Photo=Pic", " =_"}, name: "IMG 1 2", expected: "Pic_1_2"},
+		{rules: []string{`a\=b=c\=d`}, name: "xa=by", expected: "xc=dy"},
+		{rules: []string{"s/a=b/c/"}, name: "a=b", expected: "c"},
+		{rules: []string{"s|/|-|"}, name: "a/b", expected: "a-b"},
+		{rules: []string{"s/img/Photo/i"}, name: "IMG_1", expected: "Photo_1"},
+		{rules: []string{"s/(1)/$2/l"}, name: "a(1)", expected: "a$2"},
+		{rules: []string{"(1)=[1]"}, opts: replaceOptions{literal: true}, name: "a(1)", expected: "a[1]"},
+		{rules: []string{"copy="}, opts: replaceOptions{ignoreCase: true}, name: "a Copy", expected: "a "},
+		{rules: []string{"s.jpg=x"}, name: "s.jpg", expected: "x"},
+	}
+
+	for _, tc := range testCases {
+		r, err := getReplacer(tc.rules, "", tc.opts)
+		if err != nil {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual error: '%v'", tc.rules, tc.expected, err)
+			continue
+		}
+
+		if result := r.replace(tc.name); result != tc.expected {
+			t.Errorf("FAIL => Input: %v %v, Expected: '%v' - Actual: '%v'", tc.rules, tc.name, tc.expected, result)
+		}
+	}
+}
+
+func TestReplacerErrors(t *testing.T) {
+	for _, rule := range []string{"no-separator", "a=b=c", "=new", "([a=b"} {
+		if _, err := getReplacer([]string{rule}, "", replaceOptions{}); err == nil {
+			t.Errorf("FAIL => Input: %v, Expected: error - Actual: nil", rule)
+		}
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.txt")
+	os.WriteFile(rulesFile, []byte("# camera prefix\nIMG_=\n\ns/ \\(copy\\)//i\n"), 0644)
+
+	r, err := getReplacer([]string{"^=2024-"}, rulesFile, replaceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := r.replace("IMG_0001 (Copy)"); result != "2024-0001" {
+		t.Errorf("FAIL => Expected: '2024-0001' - Actual: '%v'", result)
+	}
+}
@@ -0,0 +1,394 @@
+package rename
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type ffprobeOutput struct {
+	Streams []struct {
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		CodecName    string `json:"codec_name"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Tags         struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// mediaInfo is what a single probe tells about a photo or video. Width and height are the
+// displayed ones, already swapped when the stream is rotated by 90 or 270 degrees
+type mediaInfo struct {
+	width, height int
+	duration      float64
+	fps           float64
+	codec         string
+}
+
+// mediaCache keeps the probe result and the content hash of each file, so a file is probed
+// and hashed only once per run whatever the number of placeholders in the template
+type mediaCache struct {
+	mu     sync.Mutex
+	infos  map[string]*mediaInfo
+	hashes map[string]string
+	// Whether duration, fps or codec are needed, they are only read by ffprobe
+	full bool
+	// Number of files that needed ffprobe while it is not installed
+	missingFFProbe int
+}
+
+var videoExtensions = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".avi":  true,
+	".mkv":  true,
+	".webm": true,
+	".m4v":  true,
+	".3gp":  true,
+	".flv":  true,
+	".wmv":  true,
+	".mpg":  true,
+	".mpeg": true,
+	".m2v":  true,
+	".mts":  true,
+	".m2ts": true,
+}
+
+// Photos supported by Go standard library (fast, no ffprobe needed)
+var goSupportedPhotos = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// Photos not supported by Go standard library, their header is parsed and ffprobe is only a
+// fallback
+var headerPhotos = map[string]bool{
+	".heic": true,
+	".heif": true,
+	".avif": true,
+	".webp": true,
+}
+
+// Media stored in ISO base media file format boxes, their metadata can be read without ffprobe
+var isobmffExtensions = map[string]bool{
+	".heic": true,
+	".heif": true,
+	".avif": true,
+	".mp4":  true,
+	".mov":  true,
+	".m4v":  true,
+	".3gp":  true,
+}
+
+var matroskaExtensions = map[string]bool{
+	".mkv":  true,
+	".webm": true,
+}
+
+var ffprobeOnce = sync.OnceValue(func() bool {
+	_, err := exec.Command("ffprobe", "-version").Output()
+	return err == nil
+})
+
+// isHasFFProbe looks for ffprobe the first time a file needs it
+func isHasFFProbe() bool {
+	return ffprobeOnce()
+}
+
+func isMediaFile(file os.DirEntry) bool {
+	ext := strings.ToLower(filepath.Ext(file.Name()))
+	return goSupportedPhotos[ext] || headerPhotos[ext] || videoExtensions[ext]
+}
+
+func isGoSupportedPhoto(ext string) bool {
+	return goSupportedPhotos[strings.ToLower(ext)]
+}
+
+func isISOBMFFPhoto(ext string) bool {
+	ext = strings.ToLower(ext)
+	return headerPhotos[ext] && isobmffExtensions[ext]
+}
+
+func isQuickTimeVideo(ext string) bool {
+	ext = strings.ToLower(ext)
+	return videoExtensions[ext] && isobmffExtensions[ext]
+}
+
+// getImageResolution reads image dimensions using Go standard library (very fast, only reads header)
+func getImageResolution(filePath string) (int, int) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0
+	}
+
+	return config.Width, config.Height
+}
+
+func newMediaCache(full bool) *mediaCache {
+	return &mediaCache{infos: map[string]*mediaInfo{}, hashes: map[string]string{}, full: full}
+}
+
+// get returns the cached info of the file, or probes it. A nil cache always probes
+func (c *mediaCache) get(file os.DirEntry, path string) *mediaInfo {
+	if c == nil {
+		info, _ := getMediaInfo(file, path, true)
+		return info
+	}
+
+	filePath := filepath.Join(path, file.Name())
+
+	c.mu.Lock()
+	info, ok := c.infos[filePath]
+	c.mu.Unlock()
+	if ok {
+		return info
+	}
+
+	info, missingFFProbe := getMediaInfo(file, path, c.full)
+
+	c.mu.Lock()
+	c.infos[filePath] = info
+	if missingFFProbe {
+		c.missingFFProbe++
+	}
+	c.mu.Unlock()
+
+	return info
+}
+
+// parseFrameRate parses ffprobe rates such as "30000/1001" or "25/1"
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func parseFFProbeOutput(output []byte) *mediaInfo {
+	var ffProbeOutput ffprobeOutput
+	if err := json.Unmarshal(output, &ffProbeOutput); err != nil {
+		return &mediaInfo{}
+	}
+
+	info := &mediaInfo{}
+	info.duration, _ = strconv.ParseFloat(ffProbeOutput.Format.Duration, 64)
+
+	if len(ffProbeOutput.Streams) == 0 {
+		return info
+	}
+
+	stream := ffProbeOutput.Streams[0]
+	info.width, info.height, info.codec = stream.Width, stream.Height, stream.CodecName
+
+	// Prefer the average rate, variable frame rate phone videos report a meaningless r_frame_rate
+	if info.fps = parseFrameRate(stream.AvgFrameRate); info.fps == 0 {
+		info.fps = parseFrameRate(stream.RFrameRate)
+	}
+
+	// Old ffprobe versions report the rotation as a tag, new ones in the display matrix
+	rotation, _ := strconv.Atoi(stream.Tags.Rotate)
+	for _, sideData := range stream.SideDataList {
+		if sideData.Rotation != 0 {
+			rotation = int(sideData.Rotation)
+		}
+	}
+	if rotation%180 != 0 {
+		info.width, info.height = info.height, info.width
+	}
+
+	return info
+}
+
+// getMediaInfoFFProbe uses ffprobe for videos and unsupported image formats
+func getMediaInfoFFProbe(filePath string) *mediaInfo {
+	output, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height,codec_name,avg_frame_rate,r_frame_rate:stream_tags=rotate:stream_side_data=rotation:format=duration",
+		"-of", "json", filePath).Output()
+	if err != nil {
+		return &mediaInfo{}
+	}
+
+	return parseFFProbeOutput(output)
+}
+
+// getHeaderResolution reads the size from the file header, without ffprobe
+func getHeaderResolution(filePath, ext string) (int, int, bool) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, false
+	}
+
+	ext = strings.ToLower(ext)
+	switch {
+	case ext == ".webp":
+		return getWebPSize(f)
+	case isISOBMFFPhoto(ext):
+		return getHEIFSize(f, info.Size())
+	case isQuickTimeVideo(ext):
+		return getQuickTimeSize(f, info.Size())
+	case matroskaExtensions[ext]:
+		return getMatroskaSize(f, info.Size())
+	}
+
+	return 0, 0, false
+}
+
+// getMediaInfo reads the size from the header when it can, ffprobe is only used for other
+// files and for the duration, fps and codec of videos (full). The second value tells that
+// ffprobe was needed but is not installed
+func getMediaInfo(file os.DirEntry, path string, full bool) (*mediaInfo, bool) {
+	if !isMediaFile(file) {
+		return &mediaInfo{}, false
+	}
+
+	filePath := filepath.Join(path, file.Name())
+	ext := filepath.Ext(file.Name())
+
+	// Use Go standard library for supported images (much faster)
+	if isGoSupportedPhoto(ext) {
+		w, h := getImageResolution(filePath)
+		return &mediaInfo{width: w, height: h}, false
+	}
+
+	info := &mediaInfo{}
+	if w, h, ok := getHeaderResolution(filePath, ext); ok {
+		info.width, info.height = w, h
+		if !full || !videoExtensions[strings.ToLower(ext)] {
+			return info, false
+		}
+	}
+
+	if !isHasFFProbe() {
+		return info, true
+	}
+	return getMediaInfoFFProbe(filePath), false
+}
+
+func (m *mediaInfo) resolution() string {
+	if m.width <= 0 || m.height <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", m.width, m.height)
+}
+
+func (m *mediaInfo) orientation() string {
+	switch {
+	case m.width <= 0 || m.height <= 0:
+		return ""
+	case m.width > m.height:
+		return "landscape"
+	case m.width < m.height:
+		return "portrait"
+	}
+	return "square"
+}
+
+// commonRatios are the ratios a resolution is snapped to when it is close enough, so e.g.
+// 1366x768 (683:384) is labeled 16:9
+var commonRatios = [][2]int{
+	{1, 1}, {5, 4}, {4, 3}, {3, 2}, {16, 10}, {16, 9}, {2, 1}, {21, 9},
+	{4, 5}, {3, 4}, {2, 3}, {10, 16}, {9, 16}, {1, 2}, {9, 21},
+}
+
+// Relative difference allowed between a resolution and the common ratio it is snapped to
+const ratioSnapTolerance = 0.02
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// aspectRatio returns the width and height of the ratio: the closest common ratio within the
+// tolerance, otherwise the GCD reduced resolution
+func aspectRatio(width, height int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+
+	ratio := float64(width) / float64(height)
+	best, bestDiff := [2]int{}, ratioSnapTolerance
+	for _, common := range commonRatios {
+		diff := math.Abs(ratio-float64(common[0])/float64(common[1])) / ratio
+		if diff <= bestDiff {
+			best, bestDiff = common, diff
+		}
+	}
+	if best[0] > 0 {
+		return best[0], best[1]
+	}
+
+	d := gcd(width, height)
+	return width / d, height / d
+}
+
+// aspectRatio formats the ratio with the separator between the two numbers, e.g. 16x9
+func (m *mediaInfo) aspectRatio(separator string) string {
+	w, h := aspectRatio(m.width, m.height)
+	if w == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%s%d", w, separator, h)
+}
+
+// formatDuration formats the duration as 00h03m12s, rounded to the second
+func formatDuration(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+
+	total := int(math.Round(seconds))
+	return fmt.Sprintf("%02dh%02dm%02ds", total/3600, total%3600/60, total%60)
+}
+
+// formatFPS keeps up to 2 decimals, e.g. 30fps or 29.97fps
+func formatFPS(fps float64) string {
+	if fps <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(fps*100)/100, 'f', -1, 64) + "fps"
+}
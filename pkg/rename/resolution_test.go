@@ -0,0 +1,240 @@
+package rename
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFFProbeOutput(t *testing.T) {
+	testCases := []struct {
+		name, output                    string
+		res, orientation, duration, fps string
+		codec                           string
+	}{
+		{
+			name:        "landscape",
+			output:      `{"streams":[{"width":1920,"height":1080,"codec_name":"h264","avg_frame_rate":"30000/1001","r_frame_rate":"30000/1001"}],"format":{"duration":"192.4"}}`,
+			res:         "1920x1080",
+			orientation: "landscape",
+			duration:    "00h03m12s",
+			fps:         "29.97fps",
+			codec:       "h264",
+		},
+		{
+			name:        "rotate tag",
+			output:      `{"streams":[{"width":1920,"height":1080,"codec_name":"hevc","avg_frame_rate":"0/0","r_frame_rate":"60/1","tags":{"rotate":"90"}}],"format":{"duration":"3725.6"}}`,
+			res:         "1080x1920",
+			orientation: "portrait",
+			duration:    "01h02m06s",
+			fps:         "60fps",
+			codec:       "hevc",
+		},
+		{
+			name:        "display matrix",
+			output:      `{"streams":[{"width":1920,"height":1080,"codec_name":"hevc","avg_frame_rate":"30/1","side_data_list":[{"rotation":-90}]}],"format":{"duration":"5"}}`,
+			res:         "1080x1920",
+			orientation: "portrait",
+			duration:    "00h00m05s",
+			fps:         "30fps",
+			codec:       "hevc",
+		},
+		{
+			name:        "upside down",
+			output:      `{"streams":[{"width":1280,"height":720,"avg_frame_rate":"25/1","tags":{"rotate":"180"}}]}`,
+			res:         "1280x720",
+			orientation: "landscape",
+			fps:         "25fps",
+		},
+		{name: "no stream", output: `{"streams":[]}`},
+		{name: "invalid", output: `not json`},
+	}
+
+	for _, tc := range testCases {
+		info := parseFFProbeOutput([]byte(tc.output))
+		actual := []string{info.resolution(), info.orientation(), formatDuration(info.duration), formatFPS(info.fps), info.codec}
+		expected := []string{tc.res, tc.orientation, tc.duration, tc.fps, tc.codec}
+
+		for i := range expected {
+			if actual[i] != expected[i] {
+				t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.name, expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	testCases := []struct {
+		width, height int
+		separator     string
+		expected      string
+	}{
+		{width: 1920, height: 1080, separator: "x", expected: "16x9"},
+		{width: 1080, height: 1920, separator: "x", expected: "9x16"},
+		{width: 1366, height: 768, separator: "x", expected: "16x9"},
+		{width: 2560, height: 1080, separator: "-", expected: "21-9"},
+		{width: 4032, height: 3024, separator: "x", expected: "4x3"},
+		{width: 1920, height: 1200, separator: "x", expected: "16x10"},
+		{width: 6000, height: 4000, separator: "by", expected: "3by2"},
+		{width: 1000, height: 1000, separator: "x", expected: "1x1"},
+		{width: 1000, height: 300, separator: "x", expected: "10x3"},
+		{width: 0, height: 1080, separator: "x", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		info := &mediaInfo{width: tc.width, height: tc.height}
+		if ratio := info.aspectRatio(tc.separator); ratio != tc.expected {
+			t.Errorf("FAIL => Input: %dx%d, Expected: '%v' - Actual: '%v'", tc.width, tc.height, tc.expected, ratio)
+		}
+	}
+}
+
+func TestMediaCache(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "photo.png")
+
+	writePNG := func(w, h int) {
+		f, err := os.Create(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		png.Encode(f, image.NewGray(image.Rect(0, 0, w, h)))
+	}
+
+	writePNG(4, 2)
+	entries, _ := os.ReadDir(dir)
+
+	cache := newMediaCache(false)
+	if res := cache.get(entries[0], dir).resolution(); res != "4x2" {
+		t.Fatalf("resolution = %q, want 4x2", res)
+	}
+
+	// The file is not probed again during the same run
+	writePNG(2, 4)
+	if res := cache.get(entries[0], dir).resolution(); res != "4x2" {
+		t.Errorf("cached resolution = %q, want 4x2", res)
+	}
+	if res := newMediaCache(false).get(entries[0], dir).resolution(); res != "2x4" {
+		t.Errorf("new run resolution = %q, want 2x4", res)
+	}
+}
+
+func buildWebP(chunk string, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(12+len(data)))
+	buf.WriteString("WEBP" + chunk)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func buildHEIFImage(withPrimary bool) []byte {
+	ispe := func(w, h uint32) []byte {
+		payload := make([]byte, 12)
+		binary.BigEndian.PutUint32(payload[4:], w)
+		binary.BigEndian.PutUint32(payload[8:], h)
+		return buildBox("ispe", payload)
+	}
+
+	// Properties: 1 thumbnail size, 2 primary size, 3 rotation by 90°
+	ipco := buildBox("ipco", ispe(320, 240), ispe(4032, 3024), buildBox("irot", []byte{1}))
+	// Item 1 (primary) => 2, 3 and item 2 (thumbnail) => 1
+	ipma := buildBox("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 2, 0, 1, 2, 0x82, 3, 0, 2, 1, 1})
+
+	boxes := [][]byte{}
+	if withPrimary {
+		boxes = append(boxes, buildBox("pitm", []byte{0, 0, 0, 0, 0, 1}))
+	}
+	boxes = append(boxes, buildBox("iprp", ipco, ipma))
+
+	ftyp := buildBox("ftyp", []byte("avif\x00\x00\x00\x00mif1avif"))
+	return append(ftyp, buildBox("meta", append([]byte{0, 0, 0, 0}, bytes.Join(boxes, nil)...))...)
+}
+
+func buildTkhd(version byte, width, height uint32, matrix [2]int32) []byte {
+	data := []byte{version, 0, 0, 0}
+	if version == 1 {
+		data = append(data, make([]byte, 48)...)
+	} else {
+		data = append(data, make([]byte, 36)...)
+	}
+
+	tail := make([]byte, 44)
+	binary.BigEndian.PutUint32(tail[0:], uint32(matrix[0]))
+	binary.BigEndian.PutUint32(tail[4:], uint32(matrix[1]))
+	binary.BigEndian.PutUint32(tail[36:], width<<16)
+	binary.BigEndian.PutUint32(tail[40:], height<<16)
+	return buildBox("tkhd", data, tail)
+}
+
+func buildEBML(id []byte, payloads ...[]byte) []byte {
+	payload := bytes.Join(payloads, nil)
+	// 8-byte sizes are valid for any element
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(payload)))
+	size[0] = 0x01
+	return bytes.Join([][]byte{id, size, payload}, nil)
+}
+
+func TestHeaderResolution(t *testing.T) {
+	vp8 := []byte{0, 0, 0, 0x9D, 0x01, 0x2A, 0x80, 0x07, 0x38, 0x04}
+	vp8l := []byte{0x2F, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(vp8l[1:], uint32(1920-1)|uint32(1080-1)<<14)
+	vp8x := []byte{0, 0, 0, 0, 0x7F, 0x07, 0, 0x37, 0x04, 0}
+
+	fixed := [2]int32{0x10000, 0}
+	rotated := [2]int32{0, 0x10000}
+	mp4 := func(tkhd []byte) []byte {
+		audio := buildBox("trak", buildTkhd(0, 0, 0, fixed))
+		return append(buildBox("ftyp", []byte("isom\x00\x00\x02\x00isom")), buildBox("moov", audio, buildBox("trak", tkhd))...)
+	}
+
+	audioTrack := buildEBML([]byte{0xAE}, buildEBML([]byte{0x83}, []byte{2}))
+	videoTrack := buildEBML([]byte{0xAE},
+		buildEBML([]byte{0x83}, []byte{1}),
+		buildEBML([]byte{0xE0}, buildEBML([]byte{0xB0}, []byte{0x05, 0x00}), buildEBML([]byte{0xBA}, []byte{0x02, 0xD0})),
+	)
+	mkv := bytes.Join([][]byte{
+		buildEBML([]byte{0x1A, 0x45, 0xDF, 0xA3}, buildEBML([]byte{0x42, 0x82}, []byte("webm"))),
+		// Segment of unknown size, as written by live encoders
+		{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		buildEBML([]byte{0x15, 0x49, 0xA9, 0x66}, buildEBML([]byte{0x2A, 0xD7, 0xB1}, []byte{0x0F, 0x42, 0x40})),
+		buildEBML([]byte{0x16, 0x54, 0xAE, 0x6B}, audioTrack, videoTrack),
+	}, nil)
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{name: "lossy.webp", data: buildWebP("VP8 ", vp8), expected: "1920x1080"},
+		{name: "lossless.webp", data: buildWebP("VP8L", vp8l), expected: "1920x1080"},
+		{name: "extended.webp", data: buildWebP("VP8X", vp8x), expected: "1920x1080"},
+		{name: "primary.avif", data: buildHEIFImage(true), expected: "3024x4032"},
+		{name: "largest.heic", data: buildHEIFImage(false), expected: "4032x3024"},
+		{name: "landscape.mp4", data: mp4(buildTkhd(0, 1920, 1080, fixed)), expected: "1920x1080"},
+		{name: "portrait.mov", data: mp4(buildTkhd(1, 1920, 1080, rotated)), expected: "1080x1920"},
+		{name: "video.webm", data: mkv, expected: "1280x720"},
+		{name: "invalid.mkv", data: []byte("not a matroska file"), expected: ""},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range testCases {
+		filePath := filepath.Join(dir, tc.name)
+		if err := os.WriteFile(filePath, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		w, h, _ := getHeaderResolution(filePath, filepath.Ext(tc.name))
+		if res := (&mediaInfo{width: w, height: h}).resolution(); res != tc.expected {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.name, tc.expected, res)
+		}
+	}
+}
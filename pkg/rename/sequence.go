@@ -0,0 +1,97 @@
+package rename
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+)
+
+const (
+	seqSortName    = "name"
+	seqSortNatural = "natural"
+	seqSortMtime   = "mtime"
+	seqSortDate    = "date"
+	seqSortSize    = "size"
+)
+
+// {n} or {n:3} for a zero-padded counter
+var seqTokenRegex = regexp.MustCompile(`\{n(?::(\d+))?\}`)
+
+var seqSorts = map[string]bool{
+	seqSortName:    true,
+	seqSortNatural: true,
+	seqSortMtime:   true,
+	seqSortDate:    true,
+	seqSortSize:    true,
+}
+
+// seqSortKey is what the items are sorted on, read once per file
+type seqSortKey struct {
+	name string
+	num  int64
+}
+
+func getSeqSortKey(item dirItem, opts *config) seqSortKey {
+	key := seqSortKey{name: item.entry.Name()}
+
+	info, err := item.entry.Info()
+	if err != nil {
+		return key
+	}
+
+	switch opts.SeqSort {
+	case seqSortMtime:
+		key.num = info.ModTime().UnixNano()
+	case seqSortSize:
+		key.num = info.Size()
+	case seqSortDate:
+		key.num = info.ModTime().UnixNano()
+		if date, _, ok := opts.dates.fileDate(filepath.Join(item.dir, key.name), info); ok {
+			key.num = date.UnixNano()
+		}
+	}
+
+	return key
+}
+
+// assignSequence numbers the items of each directory from seqStart by seqStep, in the chosen order
+func assignSequence(items []dirItem, opts *config) {
+	keys := make(map[string]seqSortKey, len(items))
+	for _, item := range items {
+		keys[filepath.Join(item.dir, item.entry.Name())] = getSeqSortKey(item, opts)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].dir != items[j].dir {
+			return items[i].dir < items[j].dir
+		}
+
+		ki := keys[filepath.Join(items[i].dir, items[i].entry.Name())]
+		kj := keys[filepath.Join(items[j].dir, items[j].entry.Name())]
+
+		switch opts.SeqSort {
+		case seqSortName:
+			return ki.name < kj.name
+		case seqSortNatural:
+			return utils.NaturalLess(ki.name, kj.name)
+		}
+
+		// Same time or size, keep a predictable order
+		if ki.num != kj.num {
+			return ki.num < kj.num
+		}
+		return utils.NaturalLess(ki.name, kj.name)
+	})
+
+	counters := map[string]int{}
+	for i := range items {
+		seq, exists := counters[items[i].dir]
+		if !exists {
+			seq = opts.SeqStart
+		}
+		items[i].seq = seq
+		counters[items[i].dir] = seq + opts.SeqStep
+	}
+}
@@ -0,0 +1,106 @@
+package rename
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssignSequence(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	// Name, content (its size) and mod time of each file. IMG_20230101.jpg is the only one
+	// with a date in its name, the others are dated by their mod time
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	files := []struct {
+		name, content string
+		modTime       time.Time
+	}{
+		{name: "IMG_10.jpg", content: "aaa", modTime: base.Add(time.Hour)},
+		{name: "IMG_2.jpg", content: "b", modTime: base.Add(3 * time.Hour)},
+		{name: "IMG_20230101.jpg", content: "cc", modTime: base.Add(2 * time.Hour)},
+		{name: "zz_dup.jpg", content: "aaa", modTime: base.Add(4 * time.Hour)},
+		{name: "sub/b.jpg", content: "d", modTime: base},
+		{name: "sub/a.jpg", content: "e", modTime: base},
+	}
+	for _, f := range files {
+		filePath := filepath.Join(dir, f.name)
+		if err := os.WriteFile(filePath, []byte(f.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(filePath, f.modTime, f.modTime)
+	}
+
+	testCases := []struct {
+		name     string
+		edit     func(o *Options)
+		expected map[string]int // old name => counter
+	}{
+		{
+			name:     "name",
+			edit:     func(o *Options) { o.SeqSort = seqSortName },
+			expected: map[string]int{"IMG_10.jpg": 1, "IMG_2.jpg": 2, "IMG_20230101.jpg": 3, "zz_dup.jpg": 4, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+		{
+			name:     "natural",
+			edit:     func(o *Options) { o.SeqSort = seqSortNatural },
+			expected: map[string]int{"IMG_2.jpg": 1, "IMG_10.jpg": 2, "IMG_20230101.jpg": 3, "zz_dup.jpg": 4, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+		{
+			name:     "mtime",
+			edit:     func(o *Options) { o.SeqSort = seqSortMtime },
+			expected: map[string]int{"IMG_10.jpg": 1, "IMG_20230101.jpg": 2, "IMG_2.jpg": 3, "zz_dup.jpg": 4, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+		{
+			name:     "date",
+			edit:     func(o *Options) { o.SeqSort, o.DateSource = seqSortDate, "filename" },
+			expected: map[string]int{"IMG_20230101.jpg": 1, "IMG_10.jpg": 2, "IMG_2.jpg": 3, "zz_dup.jpg": 4, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+		{
+			// Same size in sub, the natural order decides
+			name:     "size",
+			edit:     func(o *Options) { o.SeqSort = seqSortSize },
+			expected: map[string]int{"IMG_2.jpg": 1, "IMG_20230101.jpg": 2, "IMG_10.jpg": 3, "zz_dup.jpg": 4, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+		{
+			name:     "start and step",
+			edit:     func(o *Options) { o.SeqStart, o.SeqStep = 10, 5 },
+			expected: map[string]int{"IMG_2.jpg": 10, "IMG_10.jpg": 15, "IMG_20230101.jpg": 20, "zz_dup.jpg": 25, "sub/a.jpg": 10, "sub/b.jpg": 15},
+		},
+		{
+			// The duplicate of IMG_10.jpg is set aside before counting, the counter has no gap
+			name:     "dedupe",
+			edit:     func(o *Options) { o.SeqSort, o.Dedupe = seqSortMtime, dedupeSkip },
+			expected: map[string]int{"IMG_10.jpg": 1, "IMG_20230101.jpg": 2, "IMG_2.jpg": 3, "sub/a.jpg": 1, "sub/b.jpg": 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		opts := DefaultOptions()
+		opts.Template, opts.Recursive = "{name}_{n}", true
+		tc.edit(&opts)
+
+		plan, err := Build(context.Background(), dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := map[string]int{}
+		for _, r := range plan.Renames {
+			oldName, _ := filepath.Rel(dir, r.OldPath)
+			stem := strings.TrimSuffix(filepath.Base(r.NewPath), filepath.Ext(r.NewPath))
+			seq, _ := strconv.Atoi(stem[strings.LastIndex(stem, "_")+1:])
+			actual[filepath.ToSlash(oldName)] = seq
+		}
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.name, tc.expected, actual)
+		}
+	}
+}
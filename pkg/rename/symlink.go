@@ -0,0 +1,118 @@
+package rename
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	symlinkSkip   = "skip"
+	symlinkLink   = "link"
+	symlinkTarget = "target"
+)
+
+var symlinkPolicies = map[string]bool{
+	symlinkSkip:   true,
+	symlinkLink:   true,
+	symlinkTarget: true,
+}
+
+// symlinkReport tells how the symlink policy applied, for the summary and the dry-run output
+type symlinkReport struct {
+	// Old path => how the entry got in the plan
+	notes map[string]string
+	// "path: reason" of the symlinks that are not renamed
+	skipped []string
+	// Target => the links to it, with the target policy
+	links map[string][]string
+}
+
+func newSymlinkReport() *symlinkReport {
+	return &symlinkReport{notes: map[string]string{}, skipped: []string{}, links: map[string][]string{}}
+}
+
+func (r *symlinkReport) skip(linkPath, reason string) {
+	r.skipped = append(r.skipped, linkPath+": "+reason)
+}
+
+func isSymlink(entry os.DirEntry) bool {
+	return entry.Type()&fs.ModeSymlink != 0
+}
+
+// applySymlinkPolicy returns the items to rename under root according to --symlinks: skip
+// drops the links, link renames the links themselves and target replaces each link by the
+// file it points to. Targets outside of root, or already in the items, are not renamed. The
+// links are not updated, a renamed target leaves every link to it dangling: they are listed in
+// the report
+func applySymlinkPolicy(root string, items []dirItem, opts *config) ([]dirItem, *symlinkReport) {
+	report := newSymlinkReport()
+	kept := make([]dirItem, 0, len(items))
+
+	// A target is renamed only once, even when several links or the walk itself reach it
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !isSymlink(item.entry) {
+			seen[filepath.Join(item.dir, item.entry.Name())] = true
+		}
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+
+	for _, item := range items {
+		if !isSymlink(item.entry) {
+			kept = append(kept, item)
+			continue
+		}
+
+		linkPath := filepath.Join(item.dir, item.entry.Name())
+
+		switch opts.Symlinks {
+		case symlinkSkip:
+			report.skip(linkPath, "skipped")
+		case symlinkLink:
+			report.notes[linkPath] = "symlink renamed as a link"
+			kept = append(kept, item)
+		case symlinkTarget:
+			target, err := filepath.EvalSymlinks(linkPath)
+			if err != nil {
+				report.skip(linkPath, "broken link")
+				continue
+			}
+
+			// The walk uses root as given, while EvalSymlinks resolves every link of the path
+			if rel, err := filepath.Rel(realRoot, target); err == nil && isInside(target, realRoot) {
+				target = filepath.Join(root, rel)
+			} else {
+				report.skip(linkPath, "target outside of the path")
+				continue
+			}
+
+			info, err := os.Lstat(target)
+			switch {
+			case err != nil:
+				report.skip(linkPath, err.Error())
+			case seen[target]:
+				report.links[target] = append(report.links[target], linkPath)
+				report.skip(linkPath, "target already renamed")
+			case info.IsDir() && !opts.AllowDir:
+				report.skip(linkPath, "target is a directory")
+			default:
+				seen[target] = true
+				report.links[target] = append(report.links[target], linkPath)
+				report.notes[target] = "target of symlink " + linkPath + ", the link is not updated"
+				kept = append(kept, dirItem{dir: filepath.Dir(target), entry: fs.FileInfoToDirEntry(info)})
+			}
+		}
+	}
+
+	sort.Strings(report.skipped)
+	for _, links := range report.links {
+		sort.Strings(links)
+	}
+	return kept, report
+}
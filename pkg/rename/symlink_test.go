@@ -0,0 +1,64 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestApplySymlinkPolicy(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	writeFiles(t, dir, "file.txt", ".hidden", "sub/real.txt")
+	writeFiles(t, outside, "other.txt")
+	os.Symlink("sub/real.txt", filepath.Join(dir, "link.txt"))
+	os.Symlink(filepath.Join(dir, "sub", "real.txt"), filepath.Join(dir, "link2.txt"))
+	os.Symlink("file.txt", filepath.Join(dir, "self.txt"))
+	os.Symlink(filepath.Join(outside, "other.txt"), filepath.Join(dir, "outside.txt"))
+	os.Symlink("missing.txt", filepath.Join(dir, "broken.txt"))
+
+	testCases := []struct {
+		policy  string
+		hidden  bool
+		items   []string
+		skipped int
+	}{
+		{policy: symlinkSkip, items: []string{"file.txt"}, skipped: 5},
+		{policy: symlinkLink, hidden: true, items: []string{".hidden", "broken.txt", "file.txt", "link.txt", "link2.txt", "outside.txt", "self.txt"}},
+		// link2.txt points to the same target as link.txt, self.txt to a file that is already renamed
+		{policy: symlinkTarget, items: []string{"file.txt", "sub/real.txt"}, skipped: 4},
+	}
+
+	for _, tc := range testCases {
+		items, report := getTargetItems(dir, &config{Options: Options{Symlinks: tc.policy, Hidden: tc.hidden}})
+
+		names := []string{}
+		for _, item := range items {
+			rel, _ := filepath.Rel(dir, filepath.Join(item.dir, item.entry.Name()))
+			names = append(names, filepath.ToSlash(rel))
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, tc.items) || len(report.SkippedSymlinks) != tc.skipped {
+			t.Errorf("FAIL => Policy: %v, Expected: %v, %d skipped - Actual: %v, %v", tc.policy, tc.items, tc.skipped, names, report.SkippedSymlinks)
+		}
+	}
+
+	_, report := getTargetItems(dir, &config{Options: Options{Symlinks: symlinkTarget}})
+	if note := report.Notes[filepath.Join(dir, "sub", "real.txt")]; note != "target of symlink "+filepath.Join(dir, "link.txt")+", the link is not updated" {
+		t.Errorf("FAIL => Expected: target note - Actual: '%v'", note)
+	}
+
+	// Renaming the targets leaves these links dangling
+	expected := map[string][]string{
+		filepath.Join(dir, "file.txt"):        {filepath.Join(dir, "self.txt")},
+		filepath.Join(dir, "sub", "real.txt"): {filepath.Join(dir, "link.txt"), filepath.Join(dir, "link2.txt")},
+	}
+	if !reflect.DeepEqual(report.SymlinkTargets, expected) {
+		t.Errorf("FAIL => Expected: %v - Actual: %v", expected, report.SymlinkTargets)
+	}
+}
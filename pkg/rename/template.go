@@ -0,0 +1,451 @@
+package rename
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dynonguyen/dyno-clis/internal/utils"
+)
+
+const (
+	tokenName   = "name"
+	tokenExt    = "ext"
+	tokenDate   = "date"
+	tokenRes    = "res"
+	tokenSeq    = "n"
+	tokenParent = "parent"
+	tokenUID    = "uid"
+
+	tokenDuration    = "duration"
+	tokenFPS         = "fps"
+	tokenCodec       = "codec"
+	tokenOrientation = "orientation"
+	tokenRatio       = "ratio"
+	tokenHash        = "hash"
+
+	// Used by {ratio} without a separator, e.g. 16x9
+	defaultRatioSeparator = "x"
+	// Used by {hash} without a length, in hex characters of the SHA-256
+	defaultHashLength = 8
+
+	// Used by {date} without a format
+	defaultTemplateDateFormat = "YYYY-MM-DD"
+	// Separators next to an empty placeholder are dropped, e.g. "{res}_{name}" => "name"
+	templateSeparators = "_-. "
+)
+
+var templateTokens = map[string]bool{
+	tokenName:   true,
+	tokenExt:    true,
+	tokenDate:   true,
+	tokenRes:    true,
+	tokenSeq:    true,
+	tokenParent: true,
+	tokenUID:    true,
+
+	tokenDuration:    true,
+	tokenFPS:         true,
+	tokenCodec:       true,
+	tokenOrientation: true,
+	tokenRatio:       true,
+	tokenHash:        true,
+}
+
+// Placeholders read from the media itself, by ffprobe for videos
+var mediaTokens = []string{tokenRes, tokenDuration, tokenFPS, tokenCodec, tokenOrientation, tokenRatio}
+
+var templateFilters = map[string]func(string) string{
+	caseLower:  strings.ToLower,
+	caseUpper:  strings.ToUpper,
+	caseTitle:  toTitleCase,
+	caseCamel:  caseTransforms[caseCamel],
+	caseSnake:  caseTransforms[caseSnake],
+	caseKebab:  caseTransforms[caseKebab],
+	"trim":     strings.TrimSpace,
+	"slug":     slugify,
+	"ascii":    toASCII,
+	"collapse": collapseSpaces,
+	"safe":     toSafeName,
+}
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// templatePart is either a literal text or a {name:arg|filter} placeholder
+type templatePart struct {
+	literal   string
+	token     string
+	arg       string
+	filters   []string
+	isLiteral bool
+	// Literal dropped next to an empty placeholder
+	isSeparator bool
+	// Format of a {date} placeholder, parsed from arg
+	date utils.DateFormat
+}
+
+type nameTemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+// templateContext holds what a placeholder needs to be rendered for an item
+type templateContext struct {
+	item     dirItem
+	opts     *config
+	replacer *replacer
+	media    *mediaCache
+}
+
+func slugify(s string) string {
+	return strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(toASCII(s)), "-"), "-")
+}
+
+// parseTemplate parses e.g. "{date:YYYY-MM-DD}_{name|lower}_{res}", use {{ and }} for literal braces
+func parseTemplate(raw string) (*nameTemplate, error) {
+	t := &nameTemplate{raw: raw}
+	literal := strings.Builder{}
+
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			t.parts = append(t.parts, templatePart{literal: literal.String(), isLiteral: true, isSeparator: isTemplateSeparator(literal.String())})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+
+		switch {
+		case c == '{' && i+1 < len(raw) && raw[i+1] == '{':
+			literal.WriteByte('{')
+			i++
+		case c == '}' && i+1 < len(raw) && raw[i+1] == '}':
+			literal.WriteByte('}')
+			i++
+		case c == '}':
+			return nil, fmt.Errorf("invalid template: %s, unexpected } at %d (use }} for a literal })", raw, i)
+		case c == '{':
+			end := strings.IndexByte(raw[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid template: %s, missing } for the placeholder at %d", raw, i)
+			}
+
+			part, err := parseTemplatePart(raw[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid template: %s, %v", raw, err)
+			}
+
+			flushLiteral()
+			t.parts = append(t.parts, part)
+			i += end
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	flushLiteral()
+
+	return t, nil
+}
+
+func parseTemplatePart(content string) (templatePart, error) {
+	fields := strings.Split(content, "|")
+	token, arg, _ := strings.Cut(strings.TrimSpace(fields[0]), ":")
+
+	if !templateTokens[token] {
+		return templatePart{}, fmt.Errorf("unknown placeholder {%s}", token)
+	}
+
+	part := templatePart{token: token, arg: arg}
+	for _, filter := range fields[1:] {
+		filter = strings.TrimSpace(filter)
+		if _, ok := templateFilters[filter]; !ok {
+			return templatePart{}, fmt.Errorf("unknown filter |%s in {%s}", filter, content)
+		}
+		part.filters = append(part.filters, filter)
+	}
+
+	if token == tokenSeq && arg != "" {
+		if _, err := strconv.Atoi(arg); err != nil {
+			return templatePart{}, fmt.Errorf("invalid counter width in {%s}", content)
+		}
+	}
+
+	if token == tokenDate {
+		format := arg
+		if format == "" {
+			format = defaultTemplateDateFormat
+		}
+		date, err := utils.ParseDateFormat(format)
+		if err != nil {
+			return templatePart{}, err
+		}
+		part.date = date
+	}
+
+	if token == tokenHash && arg != "" {
+		if length, err := strconv.Atoi(arg); err != nil || length < 1 || length > sha256.Size*2 {
+			return templatePart{}, fmt.Errorf("invalid hash length in {%s}, expected 1 to %d", content, sha256.Size*2)
+		}
+	}
+
+	return part, nil
+}
+
+func (t *nameTemplate) has(token string) bool {
+	for _, part := range t.parts {
+		if !part.isLiteral && part.token == token {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMedia tells whether a placeholder needs to probe the media
+func (t *nameTemplate) hasMedia() bool {
+	for _, token := range mediaTokens {
+		if t.has(token) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsWorkers tells whether a placeholder reads the media or the whole content of the files
+func (t *nameTemplate) needsWorkers() bool {
+	return t.hasMedia() || t.has(tokenHash)
+}
+
+// escapeTemplateLiteral escapes the braces of a flag value, {n} counters are kept as placeholders
+func escapeTemplateLiteral(s string) string {
+	tokens := seqTokenRegex.FindAllStringIndex(s, -1)
+	escaped := strings.Builder{}
+
+	last := 0
+	for _, loc := range tokens {
+		escaped.WriteString(strings.NewReplacer("{", "{{", "}", "}}").Replace(s[last:loc[0]]))
+		escaped.WriteString(s[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	escaped.WriteString(strings.NewReplacer("{", "{{", "}", "}}").Replace(s[last:]))
+
+	return escaped.String()
+}
+
+// legacyTemplateParts turns the naming flags into the pieces of the equivalent template, in the
+// order they have always been applied: override → replace → date → resolution → prefix → suffix
+// → unique. The pieces are joined by the separator
+func legacyTemplateParts(opts *Options) []string {
+	parts := []string{}
+
+	if opts.Override != "" {
+		shouldEmpty := opts.Override == emptyOverrideFlag && (len(opts.Prefix) > 0 || len(opts.Suffix) > 0 || opts.UniqueSuffix)
+		if !shouldEmpty {
+			parts = append(parts, escapeTemplateLiteral(opts.Override))
+		}
+	} else {
+		parts = append(parts, "{"+tokenName+"}")
+	}
+
+	if opts.CreatedDate != "" {
+		if strings.HasPrefix(opts.CreatedDate, suffixFlag) {
+			parts = append(parts, "{"+tokenDate+":"+opts.CreatedDate[len(suffixFlag):]+"}")
+		} else {
+			parts = append([]string{"{" + tokenDate + ":" + opts.CreatedDate + "}"}, parts...)
+		}
+	}
+
+	if opts.DetectResolution != "" {
+		res := []string{"{" + tokenRes + "}"}
+		if opts.AspectRatio != "" {
+			res = append(res, "{"+tokenRatio+":"+escapeTemplateLiteral(opts.AspectRatio)+"}")
+		}
+
+		if opts.DetectResolution == suffixFlag {
+			parts = append(parts, res...)
+		} else {
+			parts = append(res, parts...)
+		}
+	}
+
+	if opts.Prefix != "" {
+		parts = append([]string{escapeTemplateLiteral(opts.Prefix)}, parts...)
+	}
+
+	if opts.Suffix != "" {
+		parts = append(parts, escapeTemplateLiteral(opts.Suffix))
+	}
+
+	if opts.UniqueSuffix {
+		parts = append(parts, "{"+tokenUID+"}")
+	}
+
+	return parts
+}
+
+// legacyTemplate returns the template of the naming flags
+func legacyTemplate(opts *Options) string {
+	return strings.Join(legacyTemplateParts(opts), escapeTemplateLiteral(opts.Separator))
+}
+
+// parseLegacyTemplate parses the template of the naming flags. Only the separators between the
+// flags are dropped next to an empty placeholder, whatever their characters, the text of the
+// flags is always kept
+func parseLegacyTemplate(opts *Options) (*nameTemplate, error) {
+	t := &nameTemplate{raw: legacyTemplate(opts)}
+
+	for i, raw := range legacyTemplateParts(opts) {
+		if i > 0 && opts.Separator != "" {
+			t.parts = append(t.parts, templatePart{literal: opts.Separator, isLiteral: true, isSeparator: true})
+		}
+
+		piece, err := parseTemplate(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range piece.parts {
+			part.isSeparator = false
+			t.parts = append(t.parts, part)
+		}
+	}
+
+	return t, nil
+}
+
+// getNameTemplate parses the template, or builds the equivalent template from the naming options
+func getNameTemplate(opts *Options) (*nameTemplate, error) {
+	if opts.Template == "" {
+		return parseLegacyTemplate(opts)
+	}
+
+	if opts.HasNamingOptions() {
+		return nil, fmt.Errorf("--template can't be combined with --prefix, --suffix, --override, --created-date, --detect-resolution, --aspect-ratio or --unique-suffix")
+	}
+
+	return parseTemplate(opts.Template)
+}
+
+func (c *templateContext) fileInfo() (os.FileInfo, error) {
+	return c.item.entry.Info()
+}
+
+func (c *templateContext) value(part templatePart) string {
+	name := c.item.entry.Name()
+	ext := filepath.Ext(name)
+
+	switch part.token {
+	case tokenName:
+		nameWoutExt := strings.TrimSuffix(name, ext)
+		if c.replacer != nil {
+			nameWoutExt = c.replacer.replace(nameWoutExt)
+		}
+		return nameWoutExt
+	case tokenExt:
+		return strings.TrimPrefix(ext, ".")
+	case tokenParent:
+		return filepath.Base(c.item.dir)
+	case tokenSeq:
+		width, _ := strconv.Atoi(part.arg)
+		return fmt.Sprintf("%0*d", width, c.item.seq)
+	case tokenUID:
+		return utils.GenUniqueStr()
+	case tokenDate:
+		info, err := c.fileInfo()
+		if err != nil {
+			return ""
+		}
+		// None of the date sources has a date for this file, the name is kept without it
+		date, _, found := c.opts.dates.fileDate(filepath.Join(c.item.dir, name), info)
+		if !found {
+			return ""
+		}
+		return part.date.Format(date)
+	case tokenRes:
+		return c.media.get(c.item.entry, c.item.dir).resolution()
+	case tokenOrientation:
+		return c.media.get(c.item.entry, c.item.dir).orientation()
+	case tokenRatio:
+		separator := part.arg
+		if separator == "" {
+			separator = defaultRatioSeparator
+		}
+		return c.media.get(c.item.entry, c.item.dir).aspectRatio(separator)
+	case tokenDuration:
+		return formatDuration(c.media.get(c.item.entry, c.item.dir).duration)
+	case tokenFPS:
+		return formatFPS(c.media.get(c.item.entry, c.item.dir).fps)
+	case tokenCodec:
+		return c.media.get(c.item.entry, c.item.dir).codec
+	case tokenHash:
+		length := defaultHashLength
+		if part.arg != "" {
+			length, _ = strconv.Atoi(part.arg)
+		}
+		if hash := c.media.hash(filepath.Join(c.item.dir, name)); hash != "" {
+			return hash[:length]
+		}
+	}
+
+	return ""
+}
+
+func isTemplateSeparator(s string) bool {
+	return s != "" && strings.Trim(s, templateSeparators) == ""
+}
+
+// render returns the new name of the item. The original extension is kept unless the
+// template has an {ext} placeholder
+func (t *nameTemplate) render(ctx *templateContext) string {
+	values := make([]string, len(t.parts))
+	dropped := make([]bool, len(t.parts))
+
+	for i, part := range t.parts {
+		if part.isLiteral {
+			values[i] = part.literal
+			continue
+		}
+
+		value := ctx.value(part)
+		for _, filter := range part.filters {
+			value = templateFilters[filter](value)
+		}
+		values[i] = value
+	}
+
+	// Drop one separator next to each empty placeholder, the one before it first
+	for i, part := range t.parts {
+		if part.isLiteral || values[i] != "" {
+			continue
+		}
+
+		switch {
+		case i > 0 && !dropped[i-1] && t.parts[i-1].isSeparator:
+			dropped[i-1] = true
+		case i+1 < len(t.parts) && t.parts[i+1].isSeparator:
+			dropped[i+1] = true
+		}
+	}
+
+	name := strings.Builder{}
+	for i, value := range values {
+		if !dropped[i] {
+			name.WriteString(value)
+		}
+	}
+
+	// Every placeholder of the file name is empty, it keeps its original name instead of becoming
+	// a hidden file like ".jpg"
+	result := name.String()
+	if i := strings.LastIndexFunc(result, isPathSeparator); strings.TrimSpace(result[i+1:]) == "" {
+		return result[:i+1] + ctx.item.entry.Name()
+	}
+
+	if !t.has(tokenExt) {
+		result += filepath.Ext(ctx.item.entry.Name())
+	}
+	return result
+}
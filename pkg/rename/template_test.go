@@ -0,0 +1,107 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLegacyTemplate(t *testing.T) {
+	testCases := []struct {
+		opts     Options
+		template string
+	}{
+		{opts: Options{Separator: "_"}, template: "{name}"},
+		{opts: Options{Separator: "_", Prefix: "IMG", Suffix: "bak"}, template: "IMG_{name}_bak"},
+		{opts: Options{Separator: "-", Override: "trip", Suffix: "{n:3}"}, template: "trip-{n:3}"},
+		{opts: Options{Separator: "_", Override: emptyOverrideFlag, Prefix: "P"}, template: "P"},
+		{opts: Options{Separator: "_", CreatedDate: "YYYYMMDD", DetectResolution: "prefix"}, template: "{res}_{date:YYYYMMDD}_{name}"},
+		{opts: Options{Separator: "_", CreatedDate: "suffixYYYYMMDD", DetectResolution: suffixFlag, UniqueSuffix: true}, template: "{name}_{date:YYYYMMDD}_{res}_{uid}"},
+		{opts: Options{Separator: "_", Prefix: "{a}"}, template: "{{a}}_{name}"},
+		{opts: Options{Separator: "_", DetectResolution: "prefix", AspectRatio: "x"}, template: "{res}_{ratio:x}_{name}"},
+	}
+
+	for _, tc := range testCases {
+		if template := legacyTemplate(&tc.opts); template != tc.template {
+			t.Errorf("FAIL => Input: %+v, Expected: '%v' - Actual: '%v'", tc.opts, tc.template, template)
+		}
+	}
+}
+
+func TestParseTemplateError(t *testing.T) {
+	for _, template := range []string{"{name", "name}", "{unknown}", "{name|shout}", "{n:abc}", "{hash:0}", "{hash:65}", "{date:YMD}", "{date:%Q}"} {
+		if _, err := parseTemplate(template); err == nil {
+			t.Errorf("FAIL => Input: %v, Expected: error - Actual: nil", template)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Trip")
+	os.Mkdir(dir, 0755)
+
+	filePath := filepath.Join(dir, "IMG 0001 (Copy).JPG")
+	os.WriteFile(filePath, []byte{}, 0644)
+	mtime := time.Date(2023, 5, 12, 10, 15, 30, 0, time.Local)
+	os.Chtimes(filePath, mtime, mtime)
+
+	entries, _ := os.ReadDir(dir)
+	item := dirItem{dir: dir, entry: entries[0], seq: 7}
+	opts := &config{dates: &dateReader{sources: []timeSource{timeSourceModify}}}
+	replacer, _ := getReplacer([]string{` \(Copy\)=`}, "", replaceOptions{})
+
+	testCases := []struct {
+		template, expected string
+	}{
+		{template: "{name}", expected: "IMG 0001.JPG"},
+		{template: "{date}_{name|slug}", expected: "2023-05-12_img-0001.JPG"},
+		{template: "{parent|lower}-{n:3}", expected: "trip-007.JPG"},
+		{template: "{name|lower}.{ext|lower}", expected: "img 0001.jpg"},
+		{template: "{res}_{name|upper}", expected: "IMG 0001.JPG"},
+		{template: "{{{n}}}", expected: "{7}.JPG"},
+		{template: "{hash}_{hash:4|upper}", expected: "e3b0c442_E3B0.JPG"},
+	}
+
+	for _, tc := range testCases {
+		template, err := parseTemplate(tc.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := template.render(&templateContext{item: item, opts: opts, replacer: replacer}); name != tc.expected {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.template, tc.expected, name)
+		}
+	}
+}
+
+func TestRenderLegacyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte{}, 0644)
+
+	entries, _ := os.ReadDir(dir)
+	item := dirItem{dir: dir, entry: entries[0]}
+	ctx := &templateContext{item: item, opts: &config{}, media: newMediaCache(false)}
+
+	// A text file has no resolution, only the separator next to it is dropped
+	testCases := []struct {
+		opts     Options
+		expected string
+	}{
+		{opts: Options{Separator: "+", DetectResolution: "prefix"}, expected: "notes.txt"},
+		{opts: Options{Separator: "+", DetectResolution: suffixFlag, AspectRatio: "x"}, expected: "notes.txt"},
+		{opts: Options{Separator: "-", Prefix: "__", DetectResolution: suffixFlag}, expected: "__-notes.txt"},
+		{opts: Options{Separator: "_", Suffix: "-", DetectResolution: "prefix"}, expected: "notes_-.txt"},
+	}
+
+	for _, tc := range testCases {
+		template, err := parseLegacyTemplate(&tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := template.render(ctx); name != tc.expected {
+			t.Errorf("FAIL => Input: %+v, Expected: '%v' - Actual: '%v'", tc.opts, tc.expected, name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package rename
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultTouchDateSource is where BuildTouch takes the dates from when Options.DateSource is
+// empty: the metadata of photos and videos, then the file name
+const DefaultTouchDateSource = "exif,quicktime,filename"
+
+// Touch is a planned change of the timestamps of a file: its mod and access times are set to
+// the date found by the date sources, the birth time too where the platform allows it
+type Touch struct {
+	Path   string
+	Date   time.Time
+	Source string // date source the date comes from, e.g. exif
+
+	// Timestamps of the file when the plan was built, BirthTime is nil when the filesystem
+	// doesn't record it
+	ModTime, AccessTime time.Time
+	BirthTime           *time.Time
+	// Birth time once applied, nil when it doesn't change
+	NewBirthTime *time.Time
+}
+
+// TouchPlan is the set of timestamp changes built by BuildTouch and run by ApplyTouch
+type TouchPlan struct {
+	CreatedAt time.Time
+	// Directory the plan was built in, and the command line that built it, set by the caller
+	Path    string
+	Args    []string
+	Touches []Touch
+	// Files without a date in any of the sources, their timestamps are kept
+	NoDate []string
+	Report *Report
+}
+
+// touchOf returns the timestamp change of the item, false when none of the sources has a date
+func (c *config) touchOf(item dirItem) (Touch, bool) {
+	filePath := filepath.Join(item.dir, item.entry.Name())
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Touch{}, false
+	}
+
+	date, source, found := c.dates.fileDate(filePath, info)
+	if !found {
+		return Touch{}, false
+	}
+
+	t := Touch{Path: filePath, Date: date, Source: string(source), ModTime: info.ModTime(), AccessTime: getFileAccessTime(info)}
+	if birth, from := getFileCreatedTime(filePath, info); from == timeSourceBirth {
+		t.BirthTime = &birth
+	}
+	t.NewBirthTime = newBirthTime(t.BirthTime, date)
+
+	return t, true
+}
+
+func (t *Touch) isChanged() bool {
+	return !t.Date.Equal(t.ModTime) || !t.Date.Equal(t.AccessTime) || t.NewBirthTime != nil
+}
+
+// BuildTouch plans to set the timestamps of the files selected by opts to their date from
+// opts.DateSource. Only the selection and date options are used. Links kept by the link policy
+// are skipped, setting their times would change the file they point to. When the context is
+// cancelled, the plan of the files already read is returned with the context error
+func BuildTouch(ctx context.Context, dir string, opts Options) (*TouchPlan, error) {
+	if opts.DateSource == "" {
+		opts.DateSource = DefaultTouchDateSource
+	}
+
+	c, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+
+	items, report := getTargetItems(dir, c)
+
+	kept := make([]dirItem, 0, len(items))
+	for _, item := range items {
+		if isSymlink(item.entry) {
+			linkPath := filepath.Join(item.dir, item.entry.Name())
+			delete(report.Notes, linkPath)
+			report.SkippedSymlinks = append(report.SkippedSymlinks, linkPath+": the times of a link are not set, use --symlinks target")
+			continue
+		}
+		kept = append(kept, item)
+	}
+	items = kept
+	report.Files = len(items)
+	sort.Strings(report.SkippedSymlinks)
+
+	touches := make([]Touch, len(items))
+	found := make([]bool, len(items))
+	done := runPool(ctx, len(items), c.Jobs, c.Progress, func(i int) {
+		touches[i], found[i] = c.touchOf(items[i])
+	})
+
+	plan := &TouchPlan{CreatedAt: time.Now(), Path: dir, Args: []string{}, Touches: []Touch{}, NoDate: []string{}, Report: report}
+	for i, item := range items {
+		if !done[i] {
+			continue
+		}
+		report.Processed++
+
+		switch {
+		case !found[i]:
+			plan.NoDate = append(plan.NoDate, filepath.Join(item.dir, item.entry.Name()))
+		case touches[i].isChanged():
+			plan.Touches = append(plan.Touches, touches[i])
+		}
+	}
+
+	sort.Slice(plan.Touches, func(i, j int) bool { return plan.Touches[i].Path < plan.Touches[j].Path })
+	sort.Strings(plan.NoDate)
+
+	return plan, ctx.Err()
+}
+
+// ApplyTouch sets the planned timestamps, after checking that the files are unchanged since the
+// plan was built. Each file is independent: one that fails keeps its times and the others are
+// still set, the failures are joined in the error. The previous mod and access times are saved
+// in the journal so Undo can restore them, the birth time can't be restored
+func ApplyTouch(plan *TouchPlan, opts ApplyOptions) (*Result, error) {
+	changed := 0
+	for _, t := range plan.Touches {
+		info, err := os.Stat(t.Path)
+		if err != nil || (!info.IsDir() && !info.ModTime().Equal(t.ModTime)) {
+			changed++
+		}
+	}
+	if changed > 0 {
+		return nil, fmt.Errorf("%d files changed since the plan was built, build a new plan", changed)
+	}
+
+	j := newJournal(plan.Path, plan.Args)
+	errs := []error{}
+
+	for _, t := range plan.Touches {
+		if err := os.Chtimes(t.Path, t.Date, t.Date); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set the times of %s: %w", t.Path, err))
+			continue
+		}
+
+		j.add(t.Path, t.Path)
+		entry := &j.Entries[len(j.Entries)-1]
+		entry.OldModTime, entry.OldAccessTime = &t.ModTime, &t.AccessTime
+
+		if t.NewBirthTime != nil {
+			if err := setFileBirthTime(t.Path, t.Date); err != nil {
+				errs = append(errs, fmt.Errorf("failed to set the birth time of %s: %w", t.Path, err))
+			}
+		}
+	}
+
+	result := &Result{Journal: j}
+	if len(j.Entries) > 0 {
+		result.JournalErr = j.saveTo(opts.StateDir)
+	}
+
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package rename
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildApplyTouch(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeFiles(t, dir, "IMG_20230512_101530.jpg", "IMG_20230601_080000.jpg", "notes.txt")
+
+	imported := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	captured := time.Date(2023, 5, 12, 10, 15, 30, 0, time.Local)
+	os.Chtimes(filepath.Join(dir, "IMG_20230512_101530.jpg"), imported, imported)
+	// Already dated, nothing to change on platforms that can't set the birth time
+	june := time.Date(2023, 6, 1, 8, 0, 0, 0, time.Local)
+	os.Chtimes(filepath.Join(dir, "IMG_20230601_080000.jpg"), june, june)
+
+	opts := DefaultOptions()
+	opts.DateSource = ""
+
+	plan, err := BuildTouch(context.Background(), dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.NoDate) != 1 || plan.NoDate[0] != filepath.Join(dir, "notes.txt") {
+		t.Errorf("no date = %v, want notes.txt", plan.NoDate)
+	}
+	if len(plan.Touches) == 0 || plan.Touches[0].Source != "filename" || !plan.Touches[0].Date.Equal(captured) {
+		t.Fatalf("touches = %v, want the first photo dated from its name", plan.Touches)
+	}
+	for _, touch := range plan.Touches[1:] {
+		if touch.NewBirthTime == nil {
+			t.Errorf("%s is already dated", touch.Path)
+		}
+	}
+
+	result, err := ApplyTouch(plan, ApplyOptions{StateDir: stateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(filepath.Join(dir, "IMG_20230512_101530.jpg"))
+	if !info.ModTime().Equal(captured) || !getFileAccessTime(info).Equal(captured) {
+		t.Errorf("FAIL => Expected: '%v' - Actual: '%v', '%v'", captured, info.ModTime(), getFileAccessTime(info))
+	}
+
+	// The plan has been run, the files changed since
+	if _, err := ApplyTouch(plan, ApplyOptions{StateDir: stateDir}); err == nil {
+		t.Errorf("expected the second apply to fail")
+	}
+
+	results, err := Undo(result.Journal, UndoOptions{StateDir: stateDir})
+	if err != nil || results[0].Status != UndoRestored || results[0].Err != nil {
+		t.Fatalf("undo = %v, %v", results, err)
+	}
+	info, _ = os.Stat(filepath.Join(dir, "IMG_20230512_101530.jpg"))
+	if !info.ModTime().Equal(imported) {
+		t.Errorf("FAIL => Expected: '%v' - Actual: '%v'", imported, info.ModTime())
+	}
+}
@@ -0,0 +1,159 @@
+package rename
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	caseLower = "lower"
+	caseUpper = "upper"
+	caseTitle = "title"
+	caseCamel = "camel"
+	caseSnake = "snake"
+	caseKebab = "kebab"
+)
+
+var caseTransforms = map[string]func(string) string{
+	caseLower: strings.ToLower,
+	caseUpper: strings.ToUpper,
+	caseTitle: toTitleCase,
+	caseCamel: toCamelCase,
+	caseSnake: func(s string) string { return strings.ToLower(strings.Join(splitWords(s), "_")) },
+	caseKebab: func(s string) string { return strings.ToLower(strings.Join(splitWords(s), "-")) },
+}
+
+// Letters that don't decompose into a base letter and a diacritic
+var asciiReplacer = strings.NewReplacer(
+	"đ", "d", "Đ", "D",
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "þ", "th", "Þ", "TH",
+)
+
+// Normalized extensions, anything else is only lowercased
+var extAliases = map[string]string{
+	".jpeg": ".jpg",
+	".jpe":  ".jpg",
+	".tiff": ".tif",
+}
+
+// Characters that are not allowed in Windows or FAT file names
+var unsafeCharsRegex = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+// Device names reserved by Windows, with or without an extension
+var reservedNameRegex = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[0-9]|lpt[0-9])$`)
+
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+// Word boundaries: anything that is not a letter or a digit, and lower → upper changes (camelCase)
+var wordSeparatorRegex = regexp.MustCompile(`[^\pL\pN]+`)
+var camelBoundaryRegex = regexp.MustCompile(`(\p{Ll}|\pN)(\p{Lu})`)
+var wordRegex = regexp.MustCompile(`[\pL\pN]+`)
+
+func splitWords(s string) []string {
+	s = camelBoundaryRegex.ReplaceAllString(s, "$1 $2")
+	return strings.Fields(wordSeparatorRegex.ReplaceAllString(s, " "))
+}
+
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// toTitleCase capitalizes each word and keeps the characters between the words
+func toTitleCase(s string) string {
+	return wordRegex.ReplaceAllStringFunc(s, capitalize)
+}
+
+func toCamelCase(s string) string {
+	words := splitWords(s)
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			words[i] = capitalize(word)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// toASCII transliterates Unicode letters to ASCII, e.g. "Đà Lạt" => "Da Lat". Characters
+// without an ASCII equivalent are dropped
+func toASCII(s string) string {
+	decomposed := norm.NFD.String(asciiReplacer.Replace(s))
+
+	result := strings.Builder{}
+	for _, r := range decomposed {
+		if r < unicode.MaxASCII && !unicode.Is(unicode.Mn, r) {
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+func collapseSpaces(s string) string {
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(s, " "))
+}
+
+// toSafeName removes characters Windows & FAT don't allow, trailing dots and spaces, and
+// renames reserved device names (CON, NUL, COM1...)
+func toSafeName(s string) string {
+	s = strings.TrimRight(unsafeCharsRegex.ReplaceAllString(s, ""), ". ")
+	if reservedNameRegex.MatchString(s) {
+		s += "_"
+	}
+	return s
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if alias, ok := extAliases[ext]; ok {
+		return alias
+	}
+	return ext
+}
+
+func validateCaseStyle(caseStyle string) error {
+	if _, ok := caseTransforms[caseStyle]; caseStyle != "" && !ok {
+		return fmt.Errorf("invalid case: %s, expected: lower, upper, title, camel, snake or kebab", caseStyle)
+	}
+	return nil
+}
+
+// applyTransforms normalizes the new name: ASCII → safe characters → whitespace → case, the
+// extension is handled on its own. A name the transforms would empty, e.g. "照片.jpg" in ASCII,
+// keeps its stem so it doesn't become a hidden ".jpg"
+func applyTransforms(name string, opts *Options) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	nameWoutExt := stem
+
+	if opts.ASCII {
+		nameWoutExt, ext = toASCII(nameWoutExt), toASCII(ext)
+	}
+	if opts.SafeChars {
+		nameWoutExt, ext = toSafeName(nameWoutExt), unsafeCharsRegex.ReplaceAllString(ext, "")
+	}
+	if opts.CollapseSpaces {
+		nameWoutExt = collapseSpaces(nameWoutExt)
+	}
+	if transform, ok := caseTransforms[opts.CaseStyle]; ok {
+		nameWoutExt = transform(nameWoutExt)
+	}
+	if opts.NormalizeExt {
+		ext = normalizeExt(ext)
+	}
+
+	if nameWoutExt == "" {
+		nameWoutExt = stem
+	}
+	return nameWoutExt + ext
+}
@@ -0,0 +1,35 @@
+package rename
+
+import "testing"
+
+func TestApplyTransforms(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{name: "IMG 0001 (Copy).JPG", opts: Options{CaseStyle: caseLower}, expected: "img 0001 (copy).JPG"},
+		{name: "IMG 0001 (Copy).JPG", opts: Options{CaseStyle: caseSnake, NormalizeExt: true}, expected: "img_0001_copy.jpg"},
+		{name: "IMG 0001 (Copy).jpeg", opts: Options{CaseStyle: caseKebab, NormalizeExt: true}, expected: "img-0001-copy.jpg"},
+		{name: "my holiday photo.png", opts: Options{CaseStyle: caseCamel}, expected: "myHolidayPhoto.png"},
+		{name: "myHolidayPhoto.png", opts: Options{CaseStyle: caseSnake}, expected: "my_holiday_photo.png"},
+		{name: "the QUICK fox-2.txt", opts: Options{CaseStyle: caseTitle}, expected: "The Quick Fox-2.txt"},
+		{name: "the quick fox.txt", opts: Options{CaseStyle: caseUpper}, expected: "THE QUICK FOX.txt"},
+		{name: "Đà Lạt – Hồ Xuân Hương.MP4", opts: Options{ASCII: true}, expected: "Da Lat  Ho Xuan Huong.MP4"},
+		{name: "Phở  bò \t Hà Nội.jpg", opts: Options{ASCII: true, CollapseSpaces: true, CaseStyle: caseKebab}, expected: "pho-bo-ha-noi.jpg"},
+		{name: "  a   b  .txt", opts: Options{CollapseSpaces: true}, expected: "a b.txt"},
+		{name: `what: "why"?.txt`, opts: Options{SafeChars: true}, expected: "what why.txt"},
+		{name: "trailing dot..txt", opts: Options{SafeChars: true}, expected: "trailing dot.txt"},
+		{name: "CON.txt", opts: Options{SafeChars: true}, expected: "CON_.txt"},
+		{name: "photo.TIFF", opts: Options{NormalizeExt: true}, expected: "photo.tif"},
+		{name: "archive.TAR.GZ", opts: Options{NormalizeExt: true}, expected: "archive.TAR.gz"},
+		{name: "照片.JPEG", opts: Options{ASCII: true, CaseStyle: caseKebab, NormalizeExt: true}, expected: "照片.jpg"},
+		{name: `???.txt`, opts: Options{SafeChars: true}, expected: "???.txt"},
+	}
+
+	for _, tc := range testCases {
+		if result := applyTransforms(tc.name, &tc.opts); result != tc.expected {
+			t.Errorf("FAIL => Input: %v, Expected: '%v' - Actual: '%v'", tc.name, tc.expected, result)
+		}
+	}
+}
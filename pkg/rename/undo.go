@@ -0,0 +1,147 @@
+package rename
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// UndoStatus is what happened to an entry of the journal, see UndoResult
+type UndoStatus string
+
+const (
+	UndoRestored UndoStatus = "restored"
+	UndoMissing  UndoStatus = "missing"  // the renamed file no longer exists
+	UndoChanged  UndoStatus = "changed"  // the renamed file has been modified since the run
+	UndoOccupied UndoStatus = "occupied" // another file now exists at the original path
+	UndoFailed   UndoStatus = "failed"
+)
+
+// UndoResult tells what Undo did with an entry of the journal, Err is set when it failed or
+// when a restored file could not get its old mod time back
+type UndoResult struct {
+	Entry  JournalEntry
+	Status UndoStatus
+	Err    error
+}
+
+// UndoOptions configures Undo
+type UndoOptions struct {
+	// Where the journal is saved back, empty for the default StateDir
+	StateDir string
+	// Also restore files that have been modified since the run
+	Force bool
+	// Only tell what would be restored
+	DryRun bool
+}
+
+// checkUndoEntry tells whether the entry can be restored without losing data
+func checkUndoEntry(entry JournalEntry, force bool) UndoStatus {
+	info, err := os.Lstat(entry.NewPath)
+	if err != nil {
+		return UndoMissing
+	}
+
+	if _, err := os.Lstat(entry.OldPath); err == nil && entry.OldPath != entry.NewPath {
+		return UndoOccupied
+	}
+
+	// Directory mod times change whenever their content is renamed, only files are compared
+	isChanged := !info.IsDir() && !entry.ModTime.IsZero() &&
+		(info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime))
+	if isChanged && !force {
+		return UndoChanged
+	}
+
+	return UndoRestored
+}
+
+// restoreTimes sets back the times the run changed, the zero time leaves one unchanged
+func restoreTimes(entry JournalEntry) error {
+	var modTime, accessTime time.Time
+	if entry.OldModTime != nil {
+		modTime = *entry.OldModTime
+	}
+	if entry.OldAccessTime != nil {
+		accessTime = *entry.OldAccessTime
+	}
+
+	if modTime.IsZero() && accessTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(entry.OldPath, accessTime, modTime)
+}
+
+// undoJournal restores the entries in reverse order, so a directory renamed after its
+// content is restored before the files inside it
+func undoJournal(j *Journal, force, dryRun bool) []UndoResult {
+	results := make([]UndoResult, 0, len(j.Entries))
+
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+		status := checkUndoEntry(entry, force)
+
+		result := UndoResult{Entry: entry, Status: status}
+
+		if status == UndoRestored && !dryRun {
+			if entry.OldPath != entry.NewPath {
+				if err := os.Rename(entry.NewPath, entry.OldPath); err != nil {
+					results = append(results, UndoResult{Entry: entry, Status: UndoFailed, Err: err})
+					continue
+				}
+			}
+			result.Err = restoreTimes(entry)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Undo restores the renames of the journal, newest first, and returns what happened to each
+// of them, then removes the directories the run created once they are empty. Entries that
+// are skipped stay in the saved journal, so the run can be undone again once sorted out. When
+// only missing or changed files are left, undoing again won't bring them back: the run is
+// marked as undone with them, so it doesn't hide the older runs from LastJournal
+func Undo(j *Journal, opts UndoOptions) ([]UndoResult, error) {
+	if j.UndoneAt != nil {
+		return nil, fmt.Errorf("run %s has already been undone at %s", j.ID, j.UndoneAt.Format(time.DateTime))
+	}
+
+	if opts.DryRun {
+		return undoJournal(j, opts.Force, true), nil
+	}
+
+	stateDir, err := StateDir(opts.StateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := undoJournal(j, opts.Force, false)
+	removeDirs(j.Dirs)
+
+	remaining := []JournalEntry{}
+	unrecoverable := true
+	for i := len(results) - 1; i >= 0; i-- {
+		switch results[i].Status {
+		case UndoRestored:
+			continue
+		case UndoOccupied, UndoFailed:
+			unrecoverable = false
+		}
+		remaining = append(remaining, results[i].Entry)
+	}
+	j.Entries = remaining
+
+	if unrecoverable {
+		undoneAt := time.Now()
+		j.UndoneAt = &undoneAt
+	}
+
+	if err := j.save(stateDir); err != nil {
+		return results, fmt.Errorf("failed to update journal: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,284 @@
+package rename
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSettle is used by Watch when WatchOptions.Settle is zero
+const DefaultSettle = 2 * time.Second
+
+// WatchOptions configures Watch, on top of the Options of Build
+type WatchOptions struct {
+	// Where the journal of the session is saved, empty for the default StateDir
+	StateDir string
+	// Command line recorded in the plans and the journal
+	Args []string
+	// How long a new file must keep the same size and mod time before it is renamed
+	Settle time.Duration
+	// Only plan the renames of the settled files, nothing is renamed and no journal is saved
+	DryRun bool
+	// Called after each batch of settled files and for the errors of the watcher, from the
+	// goroutine of Watch
+	OnBatch func(WatchBatch)
+}
+
+// WatchBatch is what Watch did with the files that settled together
+type WatchBatch struct {
+	// Plan of the settled files, nil for the errors of the watcher
+	Plan *Plan
+	// Journal of the session, every batch is added to it so a single undo reverts the whole
+	// session. Nil until a file is renamed
+	Journal *Journal
+	// Set when the batch was not renamed, or only partly
+	Err error
+	// Set when the journal could not be saved, the renames are kept
+	JournalErr error
+}
+
+// pendingFile is a new file waiting for its size and mod time to stop changing
+type pendingFile struct {
+	size      int64
+	modTime   time.Time
+	changedAt time.Time
+}
+
+type watcher struct {
+	c       *config
+	dir     string
+	opts    WatchOptions
+	fs      *fsnotify.Watcher
+	pending map[string]*pendingFile
+	// New paths of the applied renames whose own event hasn't been seen yet, it is ignored
+	targets map[string]bool
+	journal *Journal
+}
+
+// isWatched tells whether new entries of the directory can be renamed: the root, and its sub
+// directories in recursive mode within MaxDepth, hidden ones only with Hidden
+func (w *watcher) isWatched(dir string) bool {
+	if dir == w.dir {
+		return true
+	}
+	if !w.c.Recursive || (!w.c.Hidden && isHiddenFile(filepath.Base(dir))) {
+		return false
+	}
+
+	rel, err := filepath.Rel(w.dir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	depth := strings.Count(rel, string(filepath.Separator)) + 1
+	return w.c.MaxDepth == 0 || depth < w.c.MaxDepth
+}
+
+// watchTree watches root and the sub directories to watch inside it. When isNew, root has just
+// been created or moved in and the entries already inside it are pending too
+func (w *watcher) watchTree(root string, isNew bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if isNew && path != root {
+			if info, err := d.Info(); err == nil {
+				w.touch(path, info)
+			}
+		}
+
+		if d.IsDir() {
+			if !w.isWatched(path) {
+				return filepath.SkipDir
+			}
+			return w.fs.Add(path)
+		}
+		return nil
+	})
+}
+
+// touch marks the file as changed now, a rename of the session is ignored once
+func (w *watcher) touch(path string, info os.FileInfo) {
+	if w.targets[path] {
+		delete(w.targets, path)
+		return
+	}
+	w.pending[path] = &pendingFile{size: info.Size(), modTime: info.ModTime(), changedAt: time.Now()}
+}
+
+// handle tracks the entries created in the watched directories, renamed or removed ones are
+// forgotten. Writes only matter for the entries already pending: existing files are left alone
+func (w *watcher) handle(event fsnotify.Event) {
+	path := event.Name
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		delete(w.pending, path)
+		return
+	}
+
+	// Created by a rename of the session, a renamed directory is still watched
+	if event.Has(fsnotify.Create) && w.targets[path] {
+		delete(w.targets, path)
+		if info, err := os.Lstat(path); err == nil && info.IsDir() && w.c.Recursive {
+			if err := w.watchTree(path, false); err != nil {
+				w.report(WatchBatch{Err: err})
+			}
+		}
+		return
+	}
+
+	_, isPending := w.pending[path]
+	if !event.Has(fsnotify.Create) && !(event.Has(fsnotify.Write) && isPending) {
+		return
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+
+	if info.IsDir() && event.Has(fsnotify.Create) && w.c.Recursive {
+		if err := w.watchTree(path, true); err != nil {
+			w.report(WatchBatch{Err: err})
+		}
+	}
+	w.touch(path, info)
+}
+
+// settled returns the pending entries whose size and mod time haven't changed for the settle
+// duration, and forgets them
+func (w *watcher) settled(now time.Time) map[string]bool {
+	ready := map[string]bool{}
+
+	for path, p := range w.pending {
+		info, err := os.Lstat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+
+		if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			p.size, p.modTime, p.changedAt = info.Size(), info.ModTime(), now
+			continue
+		}
+
+		if now.Sub(p.changedAt) >= w.opts.Settle {
+			ready[path] = true
+			delete(w.pending, path)
+		}
+	}
+
+	return ready
+}
+
+func (w *watcher) report(batch WatchBatch) {
+	if w.opts.OnBatch != nil {
+		w.opts.OnBatch(batch)
+	}
+}
+
+// renameBatch plans the renames of the settled entries with the same rules as Build, and runs
+// them into the journal of the session
+func (w *watcher) renameBatch(ctx context.Context, only map[string]bool) {
+	renamed, report := w.c.planRenames(ctx, w.dir, only)
+	if ctx.Err() != nil {
+		return
+	}
+
+	plan, err := newPlan(w.dir, renamed, w.c)
+	plan.Args, plan.Report = w.opts.Args, report
+	batch := WatchBatch{Plan: plan, Journal: w.journal, Err: err}
+
+	if err != nil || w.opts.DryRun || len(plan.Renames) == 0 {
+		w.report(batch)
+		return
+	}
+
+	checked, err := plan.check()
+	if err != nil {
+		batch.Err = err
+		w.report(batch)
+		return
+	}
+
+	if w.journal == nil {
+		w.journal = newJournal(w.dir, w.opts.Args)
+	}
+	batch.Journal = w.journal
+	applied := len(w.journal.Entries)
+	batch.Err = plan.apply(checked, w.journal)
+
+	// The events of the renames are read once the batch is done, their Create is ignored. The
+	// ones outside of the watched directories never get it
+	for _, e := range w.journal.Entries[applied:] {
+		if w.isWatched(filepath.Dir(e.NewPath)) {
+			w.targets[e.NewPath] = true
+		}
+	}
+
+	if len(w.journal.Entries) > 0 {
+		batch.JournalErr = w.journal.saveTo(w.opts.StateDir)
+	}
+
+	w.report(batch)
+}
+
+// Watch renames the entries that are created in dir, or moved into it, once their size and mod
+// time stop changing for WatchOptions.Settle. The entries are selected and renamed with the
+// same options as Build, each batch of settled entries is planned on its own so the {n}
+// counter starts again. Entries that exist when Watch starts are left alone. Watch runs until
+// ctx is cancelled, it only returns an error when dir can't be watched
+func Watch(ctx context.Context, dir string, opts Options, wopts WatchOptions) error {
+	c, err := newConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+
+	if wopts.Settle <= 0 {
+		wopts.Settle = DefaultSettle
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsWatcher.Close()
+
+	w := &watcher{c: c, dir: dir, opts: wopts, fs: fsWatcher, pending: map[string]*pendingFile{}, targets: map[string]bool{}}
+	if err := w.watchTree(dir, false); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(max(wopts.Settle/4, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			w.handle(event)
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.report(WatchBatch{Err: err})
+		case now := <-ticker.C:
+			if ready := w.settled(now); len(ready) > 0 {
+				w.renameBatch(ctx, ready)
+			}
+		}
+	}
+}
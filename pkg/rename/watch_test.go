@@ -0,0 +1,111 @@
+package rename
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWatch runs Watch on dir until stop is called, waitBatch returns the next batch
+func startWatch(t *testing.T, dir, stateDir string, opts Options) (waitBatch func() WatchBatch, stop func()) {
+	batches := make(chan WatchBatch, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, dir, opts, WatchOptions{StateDir: stateDir, Settle: 100 * time.Millisecond, OnBatch: func(b WatchBatch) { batches <- b }})
+	}()
+
+	waitBatch = func() WatchBatch {
+		select {
+		case b := <-batches:
+			if b.Err != nil || b.JournalErr != nil {
+				t.Fatal(b.Err, b.JournalErr)
+			}
+			return b
+		case <-time.After(5 * time.Second):
+			t.Fatal("no batch renamed")
+		}
+		return WatchBatch{}
+	}
+
+	stop = func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Give the watcher the time to start, the files that exist before are left alone
+	time.Sleep(200 * time.Millisecond)
+	return waitBatch, stop
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := t.TempDir()
+	writeFiles(t, dir, "old.jpg")
+
+	opts := DefaultOptions()
+	opts.Prefix = "P"
+
+	waitBatch, stop := startWatch(t, dir, stateDir, opts)
+	writeFiles(t, dir, "a.jpg")
+	first := waitBatch()
+
+	f, err := os.Create(filepath.Join(dir, "b.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Still growing after the settle duration, it must not be renamed yet
+	for range 3 {
+		f.WriteString("b.jpg")
+		time.Sleep(60 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.jpg")); err != nil {
+		t.Errorf("b.jpg was renamed while growing")
+	}
+	f.Close()
+	second := waitBatch()
+
+	stop()
+
+	if readContent(dir, "P_a.jpg") != "a.jpg" || readContent(dir, "P_b.jpg") != "b.jpgb.jpgb.jpg" || readContent(dir, "old.jpg") != "old.jpg" {
+		t.Errorf("FAIL => Expected: a.jpg and b.jpg renamed, old.jpg kept")
+	}
+	if first.Journal.ID != second.Journal.ID || len(second.Journal.Entries) != 2 {
+		t.Fatalf("journals = %s, %s, want one journal with 2 entries", first.Journal.ID, second.Journal.ID)
+	}
+
+	j, err := LastJournal(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(j, UndoOptions{StateDir: stateDir}); err != nil {
+		t.Fatal(err)
+	}
+	if readContent(dir, "a.jpg") != "a.jpg" || readContent(dir, "b.jpg") == "" {
+		t.Errorf("FAIL => Expected: the session undone")
+	}
+}
+
+func TestWatchReusedName(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.Prefix = "P"
+
+	waitBatch, stop := startWatch(t, dir, t.TempDir(), opts)
+	defer stop()
+
+	writeFiles(t, dir, "a.jpg")
+	waitBatch()
+
+	// A new file that takes the name of a renamed one is renamed too
+	os.Remove(filepath.Join(dir, "P_a.jpg"))
+	writeFiles(t, dir, "P_a.jpg")
+	if b := waitBatch(); len(b.Plan.Renames) != 1 || b.Plan.Renames[0].NewPath != filepath.Join(dir, "P_P_a.jpg") {
+		t.Errorf("FAIL => Expected: P_a.jpg renamed to P_P_a.jpg - Actual: %v", b.Plan.Renames)
+	}
+}
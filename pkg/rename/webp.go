@@ -0,0 +1,49 @@
+package rename
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// getWebPSize reads the canvas size from the first chunk of lossy (VP8), lossless (VP8L) and
+// extended (VP8X) WebP files
+func getWebPSize(r io.ReaderAt) (int, int, bool) {
+	// RIFF header (12 bytes), chunk header (8 bytes), then 10 bytes of the chunk are enough
+	header := make([]byte, 30)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, 0, false
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+
+	le := binary.LittleEndian
+	var width, height int
+
+	switch string(header[12:16]) {
+	case "VP8 ":
+		// Frame tag (3 bytes), start code, then 14-bit width and height
+		if header[23] != 0x9D || header[24] != 0x01 || header[25] != 0x2A {
+			return 0, 0, false
+		}
+		width = int(le.Uint16(header[26:28]) & 0x3FFF)
+		height = int(le.Uint16(header[28:30]) & 0x3FFF)
+	case "VP8L":
+		// Signature, then 14-bit width - 1 and height - 1
+		if header[20] != 0x2F {
+			return 0, 0, false
+		}
+		bits := le.Uint32(header[21:25])
+		width = int(bits&0x3FFF) + 1
+		height = int(bits>>14&0x3FFF) + 1
+	case "VP8X":
+		// Flags (4 bytes), then 24-bit canvas width - 1 and height - 1
+		width = int(uint32(header[24])|uint32(header[25])<<8|uint32(header[26])<<16) + 1
+		height = int(uint32(header[27])|uint32(header[28])<<8|uint32(header[29])<<16) + 1
+	default:
+		return 0, 0, false
+	}
+
+	return width, height, width > 0 && height > 0
+}